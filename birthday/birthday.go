@@ -1,28 +1,71 @@
 package commands
 
 import (
+	"context"
+	"sync"
 	"time"
 
-	"github.com/bwmarrin/discordgo"
 	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/joshjennings98/discord-bot/platform"
+	"github.com/joshjennings98/discord-bot/utils"
 )
 
-type BotConfiguration struct {
-	Token      string `mapstructure:"token"`
+// DefaultPrefix is the prefix for commands sent as messages.
+const DefaultPrefix = "!bd"
+
+const (
+	StoreMongoDB = "mongodb"
+	StoreBolt    = "bolt"
+	StoreMemory  = "memory"
+)
+
+type StoreConfiguration struct {
+	Store      string `mapstructure:"store"`
 	MongoDBURI string `mapstructure:"mongodb_uri"`
+	BoltPath   string `mapstructure:"bolt_path"`
+}
+
+func (cfg *StoreConfiguration) Validate() error {
+	var mongoRules, boltRules []validation.Rule
+	switch cfg.Store {
+	case StoreMongoDB:
+		mongoRules = append(mongoRules, validation.Required)
+	case StoreBolt:
+		boltRules = append(boltRules, validation.Required)
+	}
+	return validation.ValidateStruct(cfg,
+		validation.Field(&cfg.Store, validation.Required, validation.In(StoreMongoDB, StoreBolt, StoreMemory)),
+		validation.Field(&cfg.MongoDBURI, mongoRules...),
+		validation.Field(&cfg.BoltPath, boltRules...),
+	)
+}
+
+func DefaultStoreConfig() *StoreConfiguration {
+	return &StoreConfiguration{
+		Store:      StoreMongoDB,
+		MongoDBURI: "",
+		BoltPath:   "birthdays.db",
+	}
+}
+
+type BotConfiguration struct {
+	Token              string `mapstructure:"token"`
+	StoreConfiguration `mapstructure:",squash"`
 }
 
 func (cfg *BotConfiguration) Validate() error {
+	if err := cfg.StoreConfiguration.Validate(); err != nil {
+		return err
+	}
 	return validation.ValidateStruct(cfg,
 		validation.Field(&cfg.Token, validation.Required),
-		validation.Field(&cfg.MongoDBURI, validation.Required),
 	)
 }
 
 func DefaultBotConfig() *BotConfiguration {
 	return &BotConfiguration{
-		Token:      "",
-		MongoDBURI: "",
+		Token:              "",
+		StoreConfiguration: *DefaultStoreConfig(),
 	}
 }
 
@@ -47,13 +90,43 @@ func (a Birthdays) Swap(i, j int) {
 
 type Command struct {
 	Action   string
-	ID       string
-	DateTime string
+	Args     map[string]string
 	Channel  string
 	Server   string
+	Author   string
 	Database string
+	Prefix   string
+
+	// Interaction is set when the command came from a slash command rather than a message.
+	Interaction *platform.Interaction
+	responded   bool
+
+	ctx context.Context
+}
+
+// Context returns the context the command runs in, which is cancelled when the bot shuts down.
+func (c *Command) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// Arg returns the value of the named argument, or an empty string if it wasn't given.
+func (c *Command) Arg(name string) string {
+	return c.Args[name]
 }
 
 type DiscordBot struct {
-	session *discordgo.Session
+	messenger platform.Messenger
+	directory platform.GuildDirectory
+	store     Store
+	clock     utils.Clock
+	prefixes  sync.Map
+	scheduler *Scheduler
+
+	ctx      context.Context
+	inFlight sync.WaitGroup
+	mu       sync.Mutex
+	closing  bool
 }
@@ -0,0 +1,360 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	commonerrors "github.com/joshjennings98/discord-bot/errors"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	serversBucket    = []byte("servers")
+	birthdaysBucket  = []byte("birthdays")
+	greetingsBucket  = []byte("greetings")
+	remindersBucket  = []byte("reminders")
+	roleGrantsBucket = []byte("roleGrants")
+)
+
+// BoltStore is a Store backed by an embedded BoltDB file. Server settings are kept in the
+// "servers" bucket and each server gets its own bucket of birthdays nested in "birthdays", of sent
+// greetings nested in "greetings", of sent reminders nested in "reminders" and of birthday role grants
+// nested in "roleGrants".
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (store *BoltStore, err error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: Timeout})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", commonerrors.ErrCannotOpenDatabase, err.Error())
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{serversBucket, birthdaysBucket, greetingsBucket, remindersBucket, roleGrantsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%w: %s", commonerrors.ErrCannotOpenDatabase, err.Error())
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+func (b *BoltStore) CheckForBirthdaysInDatabase(ctx context.Context, database string, t time.Time) (birthdays []string, err error) {
+	item, err := b.GetBirthdaysFromDatabase(ctx, database)
+	if err != nil {
+		return
+	}
+
+	for _, birthdayItem := range item {
+		if IsBirthday(birthdayItem.Date, t) {
+			birthdays = append(birthdays, birthdayItem.ID)
+		}
+	}
+	return
+}
+
+func (b *BoltStore) CheckForUsersBirthdayInDatabase(ctx context.Context, database, userID string) (birthday time.Time, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		guild := tx.Bucket(birthdaysBucket).Bucket([]byte(database))
+		if guild == nil {
+			return commonerrors.ErrCannotOpenDatabase
+		}
+		value := guild.Get([]byte(userID))
+		if value == nil {
+			birthday = time.Unix(0, 0)
+			return nil
+		}
+		if err := birthday.UnmarshalText(value); err != nil {
+			return commonerrors.ErrCannotParse
+		}
+		return nil
+	})
+	return
+}
+
+func (b *BoltStore) AddBirthdayToDatabase(ctx context.Context, database, id string, date time.Time) (err error) {
+	value, err := date.MarshalText()
+	if err != nil {
+		return commonerrors.ErrCannotParse
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		guild := tx.Bucket(birthdaysBucket).Bucket([]byte(database))
+		if guild == nil {
+			return commonerrors.ErrCannotOpenDatabase
+		}
+		if err := guild.Put([]byte(id), value); err != nil {
+			return commonerrors.ErrCannotInsertIntoDB
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	log.Info(fmt.Sprintf("Added Birthday for %s on %s %d\n", id, date.Month().String(), date.Day()))
+	return
+}
+
+func (b *BoltStore) RemoveBirthdayFromDatabase(ctx context.Context, database, id string) (err error) {
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		guild := tx.Bucket(birthdaysBucket).Bucket([]byte(database))
+		if guild == nil {
+			return commonerrors.ErrCannotOpenDatabase
+		}
+		if guild.Get([]byte(id)) == nil {
+			return commonerrors.ErrIDNotInDatabase
+		}
+		if err := guild.Delete([]byte(id)); err != nil {
+			return commonerrors.ErrCannotUpdateDB
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	log.Info(fmt.Sprintf("Removed Birthday for %s", id))
+	return
+}
+
+func (b *BoltStore) GetBirthdaysFromDatabase(ctx context.Context, database string) (birthdays Birthdays, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		guild := tx.Bucket(birthdaysBucket).Bucket([]byte(database))
+		if guild == nil {
+			return commonerrors.ErrCannotOpenDatabase
+		}
+		return guild.ForEach(func(k, v []byte) error {
+			var date time.Time
+			if err := date.UnmarshalText(v); err != nil {
+				return commonerrors.ErrCannotParse
+			}
+			birthdays = append(birthdays, Birthday{ID: string(k), Date: date})
+			return nil
+		})
+	})
+	return
+}
+
+func (b *BoltStore) SetupBirthdayDatabase(ctx context.Context, database, defaultChannel, timezone, server string, hour int) (err error) {
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		servers := tx.Bucket(serversBucket)
+		var item ServerContent
+		if value := servers.Get([]byte(server)); value != nil {
+			if err := json.Unmarshal(value, &item); err != nil {
+				return commonerrors.ErrCannotParse
+			}
+		}
+		if item.SchemaVersion == 0 {
+			item.SchemaVersion = CurrentSchemaVersion
+		}
+		item.Server = server
+		item.Channel = defaultChannel
+		item.Timezone = timezone
+		item.Time = hour
+		value, err := json.Marshal(item)
+		if err != nil {
+			return commonerrors.ErrCannotParse
+		}
+		if err := servers.Put([]byte(server), value); err != nil {
+			return commonerrors.ErrCannotUpdateDB
+		}
+		if _, err := tx.Bucket(birthdaysBucket).CreateBucketIfNotExists([]byte(server)); err != nil {
+			return commonerrors.ErrCannotInsertIntoDB
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	log.Info("Database Setup Done")
+	return nil
+}
+
+func (b *BoltStore) SetServerSetting(ctx context.Context, database, setting string, value interface{}) (err error) {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		servers := tx.Bucket(serversBucket)
+		stored := servers.Get([]byte(database))
+		if stored == nil {
+			return commonerrors.ErrCannotOpenDatabase
+		}
+		updated, err := setJSONField(stored, setting, value)
+		if err != nil {
+			return err
+		}
+		if err := servers.Put([]byte(database), updated); err != nil {
+			return commonerrors.ErrCannotUpdateDB
+		}
+		return nil
+	})
+}
+
+// setJSONField sets a top level field of a JSON encoded ServerContent, checking the result still decodes.
+func setJSONField(document []byte, field string, value interface{}) (updated []byte, err error) {
+	var fields map[string]interface{}
+	if err = json.Unmarshal(document, &fields); err != nil {
+		return nil, commonerrors.ErrCannotParse
+	}
+	fields[field] = value
+	if updated, err = json.Marshal(fields); err != nil {
+		return nil, commonerrors.ErrCannotParse
+	}
+	var item ServerContent
+	if err = json.Unmarshal(updated, &item); err != nil {
+		return nil, commonerrors.ErrCannotParse
+	}
+	return updated, nil
+}
+
+func (b *BoltStore) RecordGreeting(ctx context.Context, database, id string, year int) (recorded bool, err error) {
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		guild, err := tx.Bucket(greetingsBucket).CreateBucketIfNotExists([]byte(database))
+		if err != nil {
+			return commonerrors.ErrCannotInsertIntoDB
+		}
+		key := []byte(fmt.Sprintf("%d/%s", year, id))
+		if guild.Get(key) != nil {
+			return nil
+		}
+		if err := guild.Put(key, []byte{}); err != nil {
+			return commonerrors.ErrCannotInsertIntoDB
+		}
+		recorded = true
+		return nil
+	})
+	return
+}
+
+func (b *BoltStore) ForgetGreeting(ctx context.Context, database, id string, year int) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		guild := tx.Bucket(greetingsBucket).Bucket([]byte(database))
+		if guild == nil {
+			return nil
+		}
+		if err := guild.Delete([]byte(fmt.Sprintf("%d/%s", year, id))); err != nil {
+			return commonerrors.ErrCannotUpdateDB
+		}
+		return nil
+	})
+}
+
+func (b *BoltStore) RecordReminder(ctx context.Context, database, id string, day time.Time) (recorded bool, err error) {
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		guild, err := tx.Bucket(remindersBucket).CreateBucketIfNotExists([]byte(database))
+		if err != nil {
+			return commonerrors.ErrCannotInsertIntoDB
+		}
+		key := []byte(fmt.Sprintf("%s/%s", day.Format(dayFormat), id))
+		if guild.Get(key) != nil {
+			return nil
+		}
+		if err := guild.Put(key, []byte{}); err != nil {
+			return commonerrors.ErrCannotInsertIntoDB
+		}
+		recorded = true
+		return nil
+	})
+	return
+}
+
+func (b *BoltStore) ForgetReminder(ctx context.Context, database, id string, day time.Time) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		guild := tx.Bucket(remindersBucket).Bucket([]byte(database))
+		if guild == nil {
+			return nil
+		}
+		if err := guild.Delete([]byte(fmt.Sprintf("%s/%s", day.Format(dayFormat), id))); err != nil {
+			return commonerrors.ErrCannotUpdateDB
+		}
+		return nil
+	})
+}
+
+func (b *BoltStore) AddRoleGrant(ctx context.Context, database string, grant RoleGrant) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		guild, err := tx.Bucket(roleGrantsBucket).CreateBucketIfNotExists([]byte(database))
+		if err != nil {
+			return commonerrors.ErrCannotInsertIntoDB
+		}
+		value, err := json.Marshal(grant)
+		if err != nil {
+			return commonerrors.ErrCannotParse
+		}
+		if err := guild.Put([]byte(grant.ID), value); err != nil {
+			return commonerrors.ErrCannotInsertIntoDB
+		}
+		return nil
+	})
+}
+
+func (b *BoltStore) GetRoleGrants(ctx context.Context, database string) (grants []RoleGrant, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		guild := tx.Bucket(roleGrantsBucket).Bucket([]byte(database))
+		if guild == nil {
+			return nil
+		}
+		return guild.ForEach(func(k, v []byte) error {
+			var grant RoleGrant
+			if err := json.Unmarshal(v, &grant); err != nil {
+				return commonerrors.ErrCannotParse
+			}
+			grants = append(grants, grant)
+			return nil
+		})
+	})
+	return
+}
+
+func (b *BoltStore) RemoveRoleGrant(ctx context.Context, database, id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		guild := tx.Bucket(roleGrantsBucket).Bucket([]byte(database))
+		if guild == nil {
+			return nil
+		}
+		if err := guild.Delete([]byte(id)); err != nil {
+			return commonerrors.ErrCannotUpdateDB
+		}
+		return nil
+	})
+}
+
+func (b *BoltStore) GetServerContent(ctx context.Context, database string) (value ServerContent, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		settings := tx.Bucket(serversBucket).Get([]byte(database))
+		if settings == nil {
+			return commonerrors.ErrCannotOpenDatabase
+		}
+		if err := json.Unmarshal(settings, &value); err != nil {
+			return commonerrors.ErrCannotParse
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	value.Birthdays, err = b.GetBirthdaysFromDatabase(ctx, database)
+	return
+}
+
+func (b *BoltStore) GetServerKeys(ctx context.Context) (keys []string, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(serversBucket).ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return
+}
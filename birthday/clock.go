@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"github.com/joshjennings98/discord-bot/utils"
+)
+
+// AttachClockToBot sets the clock used for everything that depends on the date.
+func (d *DiscordBot) AttachClockToBot(clock utils.Clock) {
+	d.clock = clock
+}
+
+func (d *DiscordBot) now() time.Time {
+	if d.clock == nil {
+		return utils.SystemClock.Now()
+	}
+	return d.clock.Now()
+}
+
+// after returns a channel that receives the time once duration has passed on the bots clock.
+func (d *DiscordBot) after(duration time.Duration) <-chan time.Time {
+	if d.clock == nil {
+		return utils.SystemClock.After(duration)
+	}
+	return d.clock.After(duration)
+}
+
+// location returns the servers timezone, or UTC if it hasn't been set up.
+func (d *DiscordBot) location(ctx context.Context, database string) *time.Location {
+	serverContent, err := d.store.GetServerContent(ctx, database)
+	if err != nil {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(serverContent.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// localNow returns the time in the servers timezone, so that "today" is the servers day rather than
+// the day wherever the bot happens to be running.
+func (d *DiscordBot) localNow(command *Command) time.Time {
+	return d.now().In(d.location(command.Context(), command.Database))
+}
+
+// IsBirthday reports whether day is the birthday. Birthdays on the 29th of February are on the 1st of
+// March in years that aren't leap years.
+func IsBirthday(birthday, day time.Time) bool {
+	date := time.Date(day.Year(), birthday.Month(), birthday.Day(), 0, 0, 0, 0, time.UTC)
+	return date.Month() == day.Month() && date.Day() == day.Day()
+}
+
+// nextOccurrence returns the start of the next day on or after now that is the birthday, in the
+// location of now.
+func nextOccurrence(birthday, now time.Time) time.Time {
+	next := time.Date(now.Year(), birthday.Month(), birthday.Day(), 0, 0, 0, 0, now.Location())
+	if next.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())) {
+		next = time.Date(now.Year()+1, birthday.Month(), birthday.Day(), 0, 0, 0, 0, now.Location())
+	}
+	return next
+}
@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"sort"
@@ -8,109 +10,217 @@ import (
 	"strings"
 	"time"
 
-	"github.com/bwmarrin/discordgo"
+	commonerrors "github.com/joshjennings98/discord-bot/errors"
+	"github.com/joshjennings98/discord-bot/platform"
 	"github.com/joshjennings98/discord-bot/utils"
 	log "github.com/sirupsen/logrus"
 )
 
 /*
 	TODO:
-	- Switch to proper '/' commands instead of checking every message?
-	- Add tests and CI
+	- Drop the '!bd' prefix commands once everyone has moved to '/' commands
 	- Make everything work asynchronously (not necessary as it isn't on more than a couple of servers)
 */
 
-var validActions = map[string]func(*DiscordBot, *Command){
-	"add":   (*DiscordBot).AddBirthday,     // add <user> <date>
-	"next":  (*DiscordBot).NextBirthday,    // next
-	"when":  (*DiscordBot).WhenBirthday,    // when <user>
-	"today": (*DiscordBot).TodaysBirthdays, // today
-	"setup": (*DiscordBot).StartDiscordBot, // setup <timezone> <time>
-	"help":  (*DiscordBot).Help,            // help
-}
-
-// Need to use backticks so can't use normal multiline strings
-const helpMessage = "**BirthdayBot Usage:**\n" +
-	"`!bd add <user> <dd/mm>` - set a users birthday in the database\n" +
-	"`!bd next` - see who is having their birthday next\n" +
-	"`!bd today` - check who is having their birthday today\n" +
-	"`!bd when <user>` - see a specific users birthday\n" +
-	"`!bd setup <timezone/tz> <hour 0..23>` - run the setup\n" +
-	"`!bd help` - see this help message"
-
 type IDiscordBot interface {
-	AttachBotToSession(session *discordgo.Session)
-	ParseInput(input string) (command Command, err error)
+	AttachMessengerToBot(messenger platform.Messenger, directory platform.GuildDirectory)
+	AttachStoreToBot(store Store)
+	AttachContextToBot(ctx context.Context)
+	AttachClockToBot(clock utils.Clock)
+	Store() Store
+	Context() context.Context
+	Drain(timeout time.Duration) bool
+	HandleMessage(m *platform.Message)
+	ParseInput(m *platform.Message) (command Command, err error)
 	ExecuteCommand(command Command)
+	ExecuteInteraction(interaction *platform.Interaction)
+	RegisterSlashCommands() error
+	Reply(command *Command, message string, err error)
+	ReplyEmbed(command *Command, reply EmbedReply)
 	StartDiscordBot(command Command)
-	WishTodaysHappyBirthdays()
+	WishHappyBirthdays(database string, day time.Time, belated bool) error
 	TodaysBirthdays(command *Command)
 	NextBirthday(command *Command)
 	AddBirthday(command *Command)
+	RemoveBirthday(command *Command)
+	MyBirthday(command *Command)
+	RestrictAdd(command *Command)
+	SetPrefix(command *Command)
+	SetReminders(command *Command)
+	SendBirthdayReminders(database string, from, to time.Time)
+	Prefix(database string) string
+	AdminRole(command *Command)
+	BirthdayRole(command *Command)
+	RemoveExpiredBirthdayRoles(database string, now time.Time)
+	Greeting(command *Command)
+	IsAdmin(command *Command) bool
+	HasPermission(command *Command, definition *CommandDefinition) bool
 	WhenBirthday(command *Command)
+	ListBirthdays(command *Command)
 	Help(command *Command)
 }
 
-func (d *DiscordBot) AttachBotToSession(session *discordgo.Session) {
-	d.session = session
+// AttachMessengerToBot sets how the bot sends messages and looks up servers, usually both are a
+// platform.DiscordSession.
+func (d *DiscordBot) AttachMessengerToBot(messenger platform.Messenger, directory platform.GuildDirectory) {
+	d.messenger = messenger
+	d.directory = directory
+}
+
+func (d *DiscordBot) AttachStoreToBot(store Store) {
+	d.store = store
+}
+
+func (d *DiscordBot) Store() Store {
+	return d.store
+}
+
+// AttachContextToBot sets the context commands and birthday messages run in. Cancelling it aborts
+// any store calls that are still running.
+func (d *DiscordBot) AttachContextToBot(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *DiscordBot) Context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// begin registers a command as in flight, or returns false if the bot is shutting down.
+func (d *DiscordBot) begin() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closing {
+		return false
+	}
+	d.inFlight.Add(1)
+	return true
+}
+
+// Drain stops the bot accepting new commands and waits for the ones in flight to finish. It returns
+// false if they didn't finish within the timeout.
+func (d *DiscordBot) Drain(timeout time.Duration) bool {
+	d.mu.Lock()
+	d.closing = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
 }
 
 func (d *DiscordBot) StartDiscordBot(command *Command) {
-	if command.ID == "" || command.DateTime == "" {
-		message := "Error parsing command: command must be in the form '!bd <action> <arg1> <arg2>'"
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
-		return
+	tz := command.Arg("timezone")
+	hour, _ := strconv.Atoi(command.Arg("hour")) // We know at this point that the hour is valid
+	var role string
+	if command.Arg("role") != "" {
+		role = utils.GetRoleIDFromMention(command.Arg("role"))
+		if message := d.checkBirthdayRole(command, role); message != "" {
+			d.Reply(command, message, nil)
+			return
+		}
+	}
+	var message string
+	err := d.store.SetupBirthdayDatabase(command.Context(), command.Database, command.Channel, tz, command.Server, hour)
+	if err == nil && role != "" {
+		err = d.store.SetServerSetting(command.Context(), command.Database, SettingBirthdayRole, role)
 	}
-	tz := command.ID
-	_, err := time.LoadLocation(tz)
 	if err != nil {
-		message := fmt.Sprintf("Invalid time zone '%s'.", tz)
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
+		message = "Failed to set up database."
+	} else {
+		d.settingsChanged()
+		message = fmt.Sprintf("Successfully set up database. Birthday messages will be sent at %s:00 in timezone '%s'.", utils.AppendZero(hour), tz)
+		if role != "" {
+			message += fmt.Sprintf(" Members will be given <@&%s> for a day on their birthday.", role)
+		}
+	}
+	d.Reply(command, message, err)
+}
+
+// settingsChanged lets the scheduler know when a server needs its birthday messages sent has changed.
+func (d *DiscordBot) settingsChanged() {
+	if d.scheduler != nil {
+		d.scheduler.Reschedule()
+	}
+}
+
+// HandleMessage runs the message as a command if it starts with the servers prefix or a mention of the
+// bot, which always works.
+func (d *DiscordBot) HandleMessage(m *platform.Message) {
+	botID := d.directory.BotID()
+	// Ignore all messages created by the bot itself
+	if m.AuthorID == botID {
 		return
 	}
-	datetime := command.DateTime
-	message := fmt.Sprintf("Invalid hour interval '%s'. The hour interval must be within 0 and 23 (inclusive).", datetime)
-	datetimeInt, err := strconv.Atoi(datetime)
-	if err != nil {
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
+	fields := strings.Fields(m.Content)
+	if len(fields) == 0 {
 		return
 	}
-	if datetimeInt < 0 || datetimeInt > 23 {
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
+	// Looking up the prefix uses the store, so the message is in flight from here on
+	if !d.begin() {
 		return
 	}
-	err = SetupBirthdayDatabase(command.Database, command.Channel, tz, command.Server, datetime)
-	if err != nil {
-		message = "Failed to set up database."
-	} else {
-		message = fmt.Sprintf("Successfully set up database in timezone '%s' with reminder between %s:00 and %s:00.", tz, utils.AppendZero(datetimeInt), utils.AppendZero((datetimeInt+1)%24))
+	defer d.inFlight.Done()
+	switch fields[0] {
+	case d.Prefix(m.ServerID), fmt.Sprintf("<@%s>", botID), fmt.Sprintf("<@!%s>", botID):
+		d.executeCommand(m)
+	}
+}
+
+func (d *DiscordBot) ExecuteCommand(input *platform.Message) {
+	if !d.begin() {
+		return
 	}
-	utils.LogAndSend(d.session, command.Channel, command.Server, message, err)
+	defer d.inFlight.Done()
+	d.executeCommand(input)
 }
 
-func (d *DiscordBot) ExecuteCommand(input *discordgo.MessageCreate) {
+func (d *DiscordBot) executeCommand(input *platform.Message) {
 	command, err := d.ParseInput(input)
 	if err != nil {
 		message := fmt.Sprintf("Error parsing command: %s.", err.Error())
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
+		d.Reply(&command, message, nil)
 		return
 	}
-	// set correct channel to execute command on
-	for action, execute := range validActions {
-		if command.Action == action {
-			execute(d, &command)
-			return
-		}
+	d.execute(&command)
+}
+
+func (d *DiscordBot) execute(command *Command) {
+	definition, ok := LookupCommand(command.Action)
+	if !ok {
+		message := fmt.Sprintf("Invalid action '%s'. Use `%s help` to see the available commands.", command.Action, command.Prefix)
+		d.Reply(command, message, nil)
+		return
+	}
+	command.Action = definition.Name
+	if err := definition.ValidateArguments(command.Prefix, command.Args); err != nil {
+		message := fmt.Sprintf("Error parsing command: %s.", err.Error())
+		d.Reply(command, message, nil)
+		return
+	}
+	if d.HasPermission(command, definition) {
+		definition.Execute(d, command)
 	}
-	message := fmt.Sprintf("Invalid action '%s'.", command.Action)
-	utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
 }
 
-func (d *DiscordBot) ParseInput(m *discordgo.MessageCreate) (command Command, err error) {
-	server := m.GuildID
+func (d *DiscordBot) ParseInput(m *platform.Message) (command Command, err error) {
+	server := m.ServerID
 	command.Server = server
 	command.Channel = m.ChannelID
+	command.Author = m.AuthorID
+	command.ctx = d.Context()
 	command.Database = filepath.Join(server /*d.databases, utils.DatabaseFromServerID(server) */)
+	command.Prefix = d.Prefix(command.Database)
 	split := strings.Split(m.Content, " ")
 	var cleanedSplitCommand []string
 	for _, str := range split {
@@ -119,162 +229,315 @@ func (d *DiscordBot) ParseInput(m *discordgo.MessageCreate) (command Command, er
 		}
 	}
 
-	commandLength := len(cleanedSplitCommand)
-
-	if commandLength < 2 || commandLength > 4 {
-		err = fmt.Errorf("command must be in the form '!bd <action> <arg1> <arg2>'")
+	if len(cleanedSplitCommand) < 2 {
+		err = fmt.Errorf("command must be in the form '%s <action> <arguments>'", command.Prefix)
 		return
 	}
 
 	command.Action = cleanedSplitCommand[1]
-
-	if commandLength > 2 {
-		command.ID = cleanedSplitCommand[2]
+	if definition, ok := LookupCommand(command.Action); ok {
+		command.Args, err = definition.BindArguments(command.Prefix, cleanedSplitCommand[2:])
 	}
-
-	if commandLength > 3 {
-		command.DateTime = cleanedSplitCommand[3]
-	}
-
 	return
 }
 
-func WishTodaysHappyBirthdays(s *discordgo.Session, database string) {
-	channel, err := GetDefaultChannel(database)
+// WishHappyBirthdays wishes everyone with their birthday on day a happy birthday, unless they already
+// have been this year. Belated greetings are for birthdays that were missed while the bot was offline.
+// It returns an error if any greeting couldn't be sent, in which case calling it again retries them.
+// The error is only platform.Permanent if none of the failures can be fixed by trying again.
+//
+// Each greeting is recorded before it is sent so that it is sent at most once, even when several
+// checks run at the same time. If the bot stops between recording a greeting and sending it the
+// greeting is lost rather than being sent twice.
+func (d *DiscordBot) WishHappyBirthdays(database string, day time.Time, belated bool) (err error) {
+	serverContent, err := d.store.GetServerContent(d.Context(), database)
 	if err != nil {
-		log.Errorf("Failed to get the default channel from the database.")
-		return
+		log.Errorf("Failed to get the server settings from the database.")
+		return err
 	}
-	server, err := GetServerID(database)
-	if err != nil {
-		log.Errorf("Failed to get the server id from the database.")
-		return
+	channel, server := serverContent.Channel, serverContent.Server
+	daysUntil := 0
+	if belated {
+		daysUntil = daysBetween(d.now().In(day.Location()), day)
 	}
-	birthdays, err := CheckForBirthdaysInDatabase(database, time.Now())
+	birthdays, err := d.store.CheckForBirthdaysInDatabase(d.Context(), database, day)
 	if err != nil {
 		log.Errorf("Failed to get todays birthdays from the database.")
-		return
+		return err
 	}
 	for _, b := range birthdays {
-		message := fmt.Sprintf("Happy Birthday <@%s>!!! :partying_face:", b)
-		utils.LogAndSend(s, channel, server, message, nil)
+		recorded, recordErr := d.store.RecordGreeting(d.Context(), database, b, day.Year())
+		if recordErr != nil {
+			log.Errorf("Failed to record birthday greeting for %s: %s", b, recordErr.Error())
+			err = recordErr
+			continue
+		}
+		if !recorded {
+			log.Info(fmt.Sprintf("Already wished %s a happy birthday in %d", b, day.Year()))
+			continue
+		}
+		data := d.greetingData(server, b)
+		data.DaysUntil, data.Belated = daysUntil, belated
+		message := d.greeting(serverContent, data)
+		if sendErr := d.send(channel, server, d.greetingEmbed(server, b, message, belated)); sendErr != nil {
+			// Forget the greeting so that it is sent when the check is retried
+			if forgetErr := d.store.ForgetGreeting(d.Context(), database, b, day.Year()); forgetErr != nil {
+				log.Errorf("Failed to forget birthday greeting for %s: %s", b, forgetErr.Error())
+			}
+			if err == nil || platform.Permanent(err) {
+				err = sendErr
+			}
+			continue
+		}
+		if !belated {
+			d.grantBirthdayRole(serverContent, database, b, day)
+		}
 	}
+	return err
 }
 
 func (d *DiscordBot) AddBirthday(command *Command) {
-	if command.ID == "" || command.DateTime == "" {
-		message := "Error parsing command: command must be in the form '!bd <action> <arg1> <arg2>'"
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
-		return
-	}
-	user := utils.GetIDFromMention(command.ID)
-	b, id := utils.IsUser(user, d.session, command.Server)
+	user := utils.GetIDFromMention(command.Arg("user"))
+	b, id := platform.IsUser(user, d.directory, command.Server)
 	if !b {
 		message := fmt.Sprintf("Invalid user '%s'.", user)
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
+		d.Reply(command, message, nil)
 		return
 	}
-	if !utils.IsValidDate(command.DateTime) {
-		message := fmt.Sprintf("Invalid date '%s'.", command.DateTime)
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
+	if d.restricted(command, id) {
+		message := fmt.Sprintf("Only moderators can set other users birthdays on this server. Use `%s me <dd/mm>` to set your own.", command.Prefix)
+		d.Reply(command, message, nil)
 		return
 	}
+	d.setBirthday(command, id, command.Arg("date"))
+}
+
+// restricted reports whether the author of the command isn't allowed to change the birthday of the
+// user with the given id because the server has restricted changing other users birthdays.
+func (d *DiscordBot) restricted(command *Command, id string) bool {
+	if id == command.Author {
+		return false
+	}
+	serverContent, err := d.store.GetServerContent(command.Context(), command.Database)
+	return err == nil && serverContent.RestrictAdd && !d.IsAdmin(command)
+}
+
+func (d *DiscordBot) setBirthday(command *Command, id, date string) {
 	var fullDate string
 	// account for leap years
-	if command.DateTime == "29/02" {
-		fullDate = fmt.Sprintf("%s/00 00:00:00 AM", command.DateTime) // Only care about the information relevant to the YearDay()
+	if date == "29/02" {
+		fullDate = fmt.Sprintf("%s/00 00:00:00 AM", date) // Only care about the information relevant to the YearDay()
 	} else {
-		fullDate = fmt.Sprintf("%s/01 00:00:00 AM", command.DateTime) // Adjust year based on whether it is a leap year
+		fullDate = fmt.Sprintf("%s/01 00:00:00 AM", date) // Adjust year based on whether it is a leap year
 	}
 	datetime, _ := time.Parse(utils.FullDateFormat, fullDate) // We know at this point that the date is valid
-	err := AddBirthdayToDatabase(command.Database, id, datetime)
+	err := d.store.AddBirthdayToDatabase(command.Context(), command.Database, id, datetime)
 	if err != nil {
 		message := fmt.Sprintf("Error adding birthday to database: %s.", err.Error())
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, err)
+		d.Reply(command, message, err)
 		return
 	}
 	message := fmt.Sprintf("Successfully set birthday for <@!%s> to %s %s.", id, datetime.Month(), utils.AddNumSuffix(datetime.Day()))
-	utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
+	d.Reply(command, message, nil)
+}
+
+func (d *DiscordBot) RemoveBirthday(command *Command) {
+	// Don't check the user is a member of the server since they may have already left
+	id := utils.GetIDFromMention(command.Arg("user"))
+	if d.restricted(command, id) {
+		message := fmt.Sprintf("Only moderators can remove other users birthdays on this server. Use `%s me forget` to remove your own.", command.Prefix)
+		d.Reply(command, message, nil)
+		return
+	}
+	d.removeBirthday(command, id)
+}
+
+func (d *DiscordBot) removeBirthday(command *Command, id string) {
+	err := d.store.RemoveBirthdayFromDatabase(command.Context(), command.Database, id)
+	if errors.Is(err, commonerrors.ErrIDNotInDatabase) {
+		message := fmt.Sprintf("<@%s>'s birthday not in database.", id)
+		d.Reply(command, message, nil)
+		return
+	}
+	if err != nil {
+		message := fmt.Sprintf("Error removing birthday from database: %s.", err.Error())
+		d.Reply(command, message, err)
+		return
+	}
+	message := fmt.Sprintf("Successfully removed birthday for <@%s>.", id)
+	d.Reply(command, message, nil)
 }
 
 func (d *DiscordBot) TodaysBirthdays(command *Command) {
-	birthdays, _ := CheckForBirthdaysInDatabase(command.Database, time.Now())
-	var message string
+	birthdays, _ := d.store.CheckForBirthdaysInDatabase(command.Context(), command.Database, d.localNow(command))
+	if len(birthdays) == 0 {
+		message := "Nobody has their birthday today :cry:"
+		d.Reply(command, message, nil)
+		return
+	}
+	var lines []string
 	for _, b := range birthdays {
-		message = fmt.Sprintf("<@%s> has their birthday today :smile:", b)
+		lines = append(lines, fmt.Sprintf("<@%s> has their birthday today :smile:", b))
 	}
-	if len(birthdays) == 0 {
-		message = "Nobody has their birthday today :cry:"
+	embed := &platform.Embed{
+		Title:       "Todays birthdays :birthday:",
+		Description: strings.Join(lines, "\n"),
+		Colour:      embedColour,
+	}
+	if len(birthdays) == 1 {
+		if member := d.member(command.Server, birthdays[0]); member != nil {
+			embed.ThumbnailURL = member.AvatarURL
+		}
 	}
-	utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
+	d.ReplyEmbed(command, EmbedReply{Embed: embed, Fallback: strings.Join(lines, "\n")})
 }
 
 func (d *DiscordBot) NextBirthday(command *Command) {
-	today := time.Now().YearDay()
-	birthdays, err := GetBirthdaysFromDatabase(command.Database)
+	birthdays, err := d.store.GetBirthdaysFromDatabase(command.Context(), command.Database)
 	if err != nil {
 		message := fmt.Sprintf("Error retrieving birthdays from database: %s.", err.Error())
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, err)
+		d.Reply(command, message, err)
 		return
 	}
 	if len(birthdays) == 0 {
 		message := "There are no birthdays in the database."
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
+		d.Reply(command, message, nil)
 		return
 	}
-	sort.Sort(birthdays) // sort by date
-
-	// These are for if we need to wrap around with birthdays. We keep track of the first birthday to reduce amount of parsing
-	var firstBirthdayDate time.Time
-	var firstBirthdayID string
+	sort.Sort(birthdays) // sort by date, so the first in the year wins if there is a tie
 
-	for i, birthday := range birthdays {
-		t := birthday.Date
-		date := int(t.YearDay())
-		// to reduce logic for wrap around
-		if i == 0 {
-			firstBirthdayDate = t
-			firstBirthdayID = birthday.ID
-		}
-		if date > today {
-			message := fmt.Sprintf("The next person to have their birthday is <@%s> in %d days on %s %s.", birthday.ID, (date - today), t.Month(), utils.AddNumSuffix(t.Day()))
-			utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
-			return
+	// Birthdays today don't count, so look for the first one from tomorrow in the servers timezone
+	now := d.localNow(command)
+	tomorrow := now.AddDate(0, 0, 1)
+	next := birthdays[0]
+	nextDate := nextOccurrence(next.Date, tomorrow)
+	for _, birthday := range birthdays[1:] {
+		if date := nextOccurrence(birthday.Date, tomorrow); date.Before(nextDate) {
+			next, nextDate = birthday, date
 		}
 	}
-	// catch any dates that have wrapped round (will only reach if no birthdays after today)
-	message := fmt.Sprintf("The next person to have their birthday is <@%s> in %d days on %s %s.", firstBirthdayID, (utils.DaysInThisYear() - today + int(firstBirthdayDate.YearDay())), firstBirthdayDate.Month(), utils.AddNumSuffix(firstBirthdayDate.Day()))
-	utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
+	message := fmt.Sprintf("The next person to have their birthday is <@%s> in %d days on %s %s.", next.ID, daysBetween(now, nextDate), nextDate.Month(), utils.AddNumSuffix(nextDate.Day()))
+	embed := d.birthdayEmbed(command.Server, next.ID, "Next birthday :calendar:", next.Date, nextDate)
+	d.ReplyEmbed(command, EmbedReply{Embed: embed, Fallback: message})
 }
 
 func (d *DiscordBot) WhenBirthday(command *Command) {
-	if command.ID == "" {
-		message := "Error parsing command: command must be in the form '!bd <action> <arg1> <arg2>'"
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
-		return
-	}
-	user := utils.GetIDFromMention(command.ID)
-	b, id := utils.IsUser(user, d.session, command.Server)
+	user := utils.GetIDFromMention(command.Arg("user"))
+	b, id := platform.IsUser(user, d.directory, command.Server)
 	if !b {
 		message := fmt.Sprintf("Invalid user '%s'.", user)
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
+		d.Reply(command, message, nil)
 		return
 	}
+	d.whenBirthday(command, id)
+}
+
+func (d *DiscordBot) whenBirthday(command *Command, id string) {
 	var message string
-	birthday, err := CheckForUsersBirthdayInDatabase(command.Database, id)
+	birthday, err := d.store.CheckForUsersBirthdayInDatabase(command.Context(), command.Database, id)
 	if err != nil {
 		message := fmt.Sprintf("Error checking for users birthday: %s.", err.Error())
-		utils.LogAndSend(d.session, command.Channel, command.Server, message, err)
+		d.Reply(command, message, err)
 		return
 	}
 	if birthday == time.Unix(0, 0) {
 		message = fmt.Sprintf("<@%s>'s birthday not in database.", id)
-	} else {
-		message = fmt.Sprintf("<@%s>'s birthday is on %s %s.", id, birthday.Month(), utils.AddNumSuffix(birthday.Day()))
+		d.Reply(command, message, nil)
+		return
+	}
+	message = fmt.Sprintf("<@%s>'s birthday is on %s %s.", id, birthday.Month(), utils.AddNumSuffix(birthday.Day()))
+	now := d.localNow(command)
+	title := "Birthday :birthday:"
+	if name := platform.DisplayName(d.directory, command.Server, id); name != "" {
+		title = fmt.Sprintf("%s's birthday :birthday:", name)
+	}
+	embed := d.birthdayEmbed(command.Server, id, title, birthday, nextOccurrence(birthday, now))
+	d.ReplyEmbed(command, EmbedReply{Embed: embed, Fallback: message})
+}
+
+// MyBirthday lets the author of the command set, show or forget their own birthday.
+func (d *DiscordBot) MyBirthday(command *Command) {
+	switch date := command.Arg("date"); date {
+	case "":
+		d.whenBirthday(command, command.Author)
+	case "forget":
+		d.removeBirthday(command, command.Author)
+	default:
+		d.setBirthday(command, command.Author, date)
+	}
+}
+
+func (d *DiscordBot) RestrictAdd(command *Command) {
+	restrict := command.Arg("enabled") == "on"
+	err := d.store.SetServerSetting(command.Context(), command.Database, SettingRestrictAdd, restrict)
+	if err != nil {
+		message := fmt.Sprintf("Error updating server settings: %s.", err.Error())
+		d.Reply(command, message, err)
+		return
+	}
+	message := "Anyone can now set or remove other users birthdays."
+	if restrict {
+		message = "Only moderators can now set or remove other users birthdays."
 	}
-	utils.LogAndSend(d.session, command.Channel, command.Server, message, nil)
+	d.Reply(command, message, nil)
+}
+
+func (d *DiscordBot) SetPrefix(command *Command) {
+	prefix := command.Arg("prefix")
+	err := d.store.SetServerSetting(command.Context(), command.Database, SettingPrefix, prefix)
+	if err != nil {
+		message := fmt.Sprintf("Error updating server settings: %s.", err.Error())
+		d.Reply(command, message, err)
+		return
+	}
+	d.prefixes.Store(command.Database, cachedPrefix{prefix: prefix})
+	message := fmt.Sprintf("Successfully set the command prefix to `%s`.", prefix)
+	d.Reply(command, message, nil)
+}
+
+// cachedPrefix is a servers prefix in the prefix cache. Prefixes that couldn't be looked up expire, so
+// the default isn't used for the rest of the process after a transient database error.
+type cachedPrefix struct {
+	prefix  string
+	expires time.Time
+}
+
+// prefixRetryDelay is how long the default prefix is used for a server whose prefix couldn't be looked up.
+const prefixRetryDelay = time.Minute
+
+// Prefix returns the command prefix for the server. Prefixes are cached so the database isn't
+// queried for every message.
+func (d *DiscordBot) Prefix(database string) string {
+	if cached, ok := d.prefixes.Load(database); ok {
+		cached := cached.(cachedPrefix)
+		if cached.expires.IsZero() || d.now().Before(cached.expires) {
+			return cached.prefix
+		}
+	}
+	serverContent, err := d.store.GetServerContent(d.Context(), database)
+	if err != nil {
+		d.prefixes.Store(database, cachedPrefix{prefix: DefaultPrefix, expires: d.now().Add(prefixRetryDelay)})
+		return DefaultPrefix
+	}
+	prefix := serverContent.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	d.prefixes.Store(database, cachedPrefix{prefix: prefix})
+	return prefix
 }
 
 func (d *DiscordBot) Help(command *Command) {
-	utils.LogAndSend(d.session, command.Channel, command.Server, helpMessage, nil)
+	name := command.Arg("command")
+	if name == "" {
+		d.Reply(command, HelpMessage(command.Prefix), nil)
+		return
+	}
+	definition, ok := LookupCommand(name)
+	if !ok {
+		message := fmt.Sprintf("Invalid action '%s'. Use `%s help` to see the available commands.", name, command.Prefix)
+		d.Reply(command, message, nil)
+		return
+	}
+	d.Reply(command, definition.Help(command.Prefix), nil)
 }
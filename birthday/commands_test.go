@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	commonerrors "github.com/joshjennings98/discord-bot/errors"
+	"github.com/joshjennings98/discord-bot/platform"
+)
+
+// flakyStore fails to get server settings while failing is set.
+type flakyStore struct {
+	*MemoryStore
+	failing bool
+}
+
+func (f *flakyStore) GetServerContent(ctx context.Context, database string) (ServerContent, error) {
+	if f.failing {
+		return ServerContent{}, commonerrors.ErrCannotOpenDatabase
+	}
+	return f.MemoryStore.GetServerContent(ctx, database)
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// After never fires since the tests using testClock don't run the scheduler loop.
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+func TestPrefixRecoversFromDatabaseErrors(t *testing.T) {
+	ctx := context.Background()
+	store := &flakyStore{MemoryStore: NewMemoryStore()}
+	if err := store.SetupBirthdayDatabase(ctx, "1", "2", "UTC", "1", 9); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetServerSetting(ctx, "1", SettingPrefix, "?bd"); err != nil {
+		t.Fatal(err)
+	}
+	clock := &testClock{now: time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)}
+	bot := &DiscordBot{}
+	bot.AttachStoreToBot(store)
+	bot.AttachClockToBot(clock)
+
+	store.failing = true
+	if prefix := bot.Prefix("1"); prefix != DefaultPrefix {
+		t.Errorf("expected the default prefix while the database is failing, got '%s'", prefix)
+	}
+	store.failing = false
+	if prefix := bot.Prefix("1"); prefix != DefaultPrefix {
+		t.Errorf("expected the default prefix to be cached briefly, got '%s'", prefix)
+	}
+	clock.now = clock.now.Add(prefixRetryDelay)
+	if prefix := bot.Prefix("1"); prefix != "?bd" {
+		t.Errorf("expected the servers prefix once the database recovers, got '%s'", prefix)
+	}
+	// Successful lookups stay cached
+	store.failing = true
+	clock.now = clock.now.Add(24 * time.Hour)
+	if prefix := bot.Prefix("1"); prefix != "?bd" {
+		t.Errorf("expected the cached prefix, got '%s'", prefix)
+	}
+}
+
+func TestDrainedBotIgnoresMessages(t *testing.T) {
+	out := &bytes.Buffer{}
+	console := platform.NewConsole(out)
+	bot := &DiscordBot{}
+	bot.AttachStoreToBot(NewMemoryStore())
+	bot.AttachMessengerToBot(console, console)
+	bot.AttachClockToBot(&testClock{now: time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)})
+
+	if !bot.Drain(time.Second) {
+		t.Fatalf("expected nothing to be in flight")
+	}
+	bot.HandleMessage(&platform.Message{ServerID: "1", ChannelID: "2", AuthorID: "3", Content: "!bd help"})
+	// The store may already be closed once the bot has been drained
+	if _, ok := bot.prefixes.Load("1"); ok {
+		t.Errorf("expected the prefix not to be looked up")
+	}
+	if out.Len() > 0 {
+		t.Errorf("expected no reply, got %q", out.String())
+	}
+}
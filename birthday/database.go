@@ -10,43 +10,140 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var BirthdaysDatabase *mongo.Database
-
 const (
 	BirthdayDatabaseName = "databases"
+	GreetingsName        = "greetings"
+	RemindersName        = "reminders"
+	RoleGrantsName       = "roleGrants"
 	Timeout              = 5 * time.Second
 )
 
-func CheckForBirthdaysInDatabase(database string, t time.Time) (birthdays []string, err error) {
-	server_db := BirthdaysDatabase.Collection(BirthdayDatabaseName)
-	ctx, _ := context.WithTimeout(context.Background(), Timeout)
+type ServerContent struct {
+	Id                primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	SchemaVersion     int                `bson:"schemaVersion,omitempty" json:"schemaVersion,omitempty"`
+	Server            string             `bson:"server,omitempty" json:"server,omitempty"`
+	Channel           string             `bson:"channel,omitempty" json:"channel,omitempty"`
+	Timezone          string             `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	Time              int                `bson:"time" json:"time"` // hour of the day to send birthday messages
+	RestrictAdd       bool               `bson:"restrictAdd,omitempty" json:"restrictAdd,omitempty"`
+	AdminRole         string             `bson:"adminRole,omitempty" json:"adminRole,omitempty"`
+	Prefix            string             `bson:"prefix,omitempty" json:"prefix,omitempty"`
+	Reminders         []int              `bson:"reminders,omitempty" json:"reminders,omitempty"`
+	LastCheck         time.Time          `bson:"lastCheck,omitempty" json:"lastCheck,omitempty"`
+	GreetingTemplates []string           `bson:"greetingTemplates,omitempty" json:"greetingTemplates,omitempty"`
+	BirthdayRole      string             `bson:"birthdayRole,omitempty" json:"birthdayRole,omitempty"`
+	Birthdays         []Birthday         `bson:"birthdays,omitempty" json:"-"`
+}
 
-	var item ServerContent
-	if err1 := server_db.FindOne(ctx, bson.M{"server": database}).Decode(&item); err1 != nil {
-		err = commonerrors.ErrCannotOpenDatabase
+// ServerKeys is the legacy document that used to list every configured server. It is only
+// read when migrating old databases; the server list is now derived from the server documents.
+type ServerKeys struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty"`
+	IsKeyList bool               `bson:"isKeyList,omitempty"`
+	Keys      []string           `bson:"keys,omitempty"`
+}
+
+// MongoStore is a Store backed by a MongoDB database.
+type MongoStore struct {
+	database *mongo.Database
+}
+
+func NewMongoStore(ctx context.Context, database *mongo.Database) (store *MongoStore, err error) {
+	store = &MongoStore{database: database}
+	if err = store.migrateServerKeys(ctx); err != nil {
+		return nil, err
+	}
+	if err = store.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// migrateServerKeys folds the legacy server key list document away, making sure every server
+// it lists has its own document first.
+func (m *MongoStore) migrateServerKeys(ctx context.Context) (err error) {
+	server_db := m.collection()
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	var item ServerKeys
+	if err = server_db.FindOne(ctx, bson.M{"isKeyList": true}).Decode(&item); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return commonerrors.ErrCannotOpenDatabase
+	}
+	for _, server := range item.Keys {
+		if _, err = server_db.UpdateOne(ctx,
+			bson.M{"server": server},
+			bson.M{"$setOnInsert": bson.M{"birthdays": []Birthday{}}},
+			options.Update().SetUpsert(true)); err != nil {
+			return commonerrors.ErrCannotUpdateDB
+		}
+	}
+	if _, err = server_db.DeleteOne(ctx, bson.M{"_id": item.Id}); err != nil {
+		return commonerrors.ErrCannotUpdateDB
+	}
+
+	log.Info(fmt.Sprintf("Migrated legacy server key list with %d servers", len(item.Keys)))
+	return nil
+}
+
+func (m *MongoStore) ensureIndexes(ctx context.Context) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	if _, err = m.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "server", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return fmt.Errorf("%w: %s", commonerrors.ErrCannotOpenDatabase, err.Error())
+	}
+	if _, err = m.database.Collection(GreetingsName).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "server", Value: 1}, {Key: "user", Value: 1}, {Key: "year", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return fmt.Errorf("%w: %s", commonerrors.ErrCannotOpenDatabase, err.Error())
+	}
+	if _, err = m.database.Collection(RemindersName).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "server", Value: 1}, {Key: "user", Value: 1}, {Key: "day", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return fmt.Errorf("%w: %s", commonerrors.ErrCannotOpenDatabase, err.Error())
+	}
+	if _, err = m.database.Collection(RoleGrantsName).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "server", Value: 1}, {Key: "user", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return fmt.Errorf("%w: %s", commonerrors.ErrCannotOpenDatabase, err.Error())
+	}
+	return nil
+}
+
+func (m *MongoStore) collection() *mongo.Collection {
+	return m.database.Collection(BirthdayDatabaseName)
+}
+
+func (m *MongoStore) CheckForBirthdaysInDatabase(ctx context.Context, database string, t time.Time) (birthdays []string, err error) {
+	item, err := m.GetServerContent(ctx, database)
+	if err != nil {
 		return
 	}
 
-	todayMonth := t.Month()
-	todayDay := t.Day()
 	for _, birthdayItem := range item.Birthdays {
-		birthday := birthdayItem.Date
-		if birthday.Month() == todayMonth && birthday.Day() == todayDay {
+		if IsBirthday(birthdayItem.Date, t) {
 			birthdays = append(birthdays, birthdayItem.ID)
 		}
 	}
 	return
 }
 
-func CheckForUsersBirthdayInDatabase(database, userID string) (birthday time.Time, err error) {
-	server_db := BirthdaysDatabase.Collection(BirthdayDatabaseName)
-	ctx, _ := context.WithTimeout(context.Background(), Timeout)
-
-	var item ServerContent
-	if err1 := server_db.FindOne(ctx, bson.M{"server": database}).Decode(&item); err1 != nil {
-		err = commonerrors.ErrCannotOpenDatabase
+func (m *MongoStore) CheckForUsersBirthdayInDatabase(ctx context.Context, database, userID string) (birthday time.Time, err error) {
+	item, err := m.GetServerContent(ctx, database)
+	if err != nil {
 		return
 	}
 
@@ -60,58 +157,70 @@ func CheckForUsersBirthdayInDatabase(database, userID string) (birthday time.Tim
 	return
 }
 
-type ServerContent struct {
-	Id        primitive.ObjectID `bson:"_id,omitempty"`
-	Server    string             `bson:"server,omitempty"`
-	Channel   string             `bson:"channel,omitempty"`
-	Timezone  string             `bson:"timezone,omitempty"`
-	Time      string             `bson:"time,omitempty"`
-	Birthdays []Birthday         `bson:"birthdays,omitempty"`
-}
+func (m *MongoStore) AddBirthdayToDatabase(ctx context.Context, database, id string, date time.Time) (err error) {
+	server_db := m.collection()
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	// Update the users entry in place if they already have one, otherwise push a new entry
+	// as long as nobody else has added one in the meantime. Retry once if we lose that race.
+	updated := false
+	for attempt := 0; attempt < 2; attempt++ {
+		result, err := server_db.UpdateOne(ctx,
+			bson.M{"server": database, "birthdays.id": id},
+			bson.M{"$set": bson.M{"birthdays.$.date": date}})
+		if err != nil {
+			return commonerrors.ErrCannotUpdateDB
+		}
+		if result.MatchedCount > 0 {
+			updated = true
+			break
+		}
+		result, err = server_db.UpdateOne(ctx,
+			bson.M{"server": database, "birthdays.id": bson.M{"$ne": id}},
+			bson.M{"$push": bson.M{"birthdays": Birthday{ID: id, Date: date}}})
+		if err != nil {
+			return commonerrors.ErrCannotInsertIntoDB
+		}
+		if result.MatchedCount > 0 {
+			updated = true
+			break
+		}
+		if _, err = m.GetServerContent(ctx, database); err != nil {
+			return err
+		}
+	}
+	if !updated {
+		return commonerrors.ErrCannotUpdateDB
+	}
 
-type ServerKeys struct {
-	Id        primitive.ObjectID `bson:"_id,omitempty"`
-	IsKeyList bool               `bson:"isKeyList,omitempty"`
-	Keys      []string           `bson:"keys,omitempty"`
+	log.Info(fmt.Sprintf("Added Birthday for %s on %s %d\n", id, date.Month().String(), date.Day()))
+	return nil
 }
 
-func AddBirthdayToDatabase(database, id string, date time.Time) (err error) {
-	server_db := BirthdaysDatabase.Collection(BirthdayDatabaseName)
-	ctx, _ := context.WithTimeout(context.Background(), Timeout)
+func (m *MongoStore) RemoveBirthdayFromDatabase(ctx context.Context, database, id string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
 
-	var item ServerContent
-
-	if err = server_db.FindOne(ctx, bson.M{"server": database}).Decode(&item); err != nil {
-		return commonerrors.ErrCannotOpenDatabase
+	result, err := m.collection().UpdateOne(ctx,
+		bson.M{"server": database, "birthdays.id": id},
+		bson.M{"$pull": bson.M{"birthdays": bson.M{"id": id}}})
+	if err != nil {
+		return commonerrors.ErrCannotUpdateDB
 	}
-
-	existsInDB := false
-	birthdays := item.Birthdays
-	for i := range birthdays {
-		if birthdays[i].ID == id {
-			birthdays[i].Date = date
-			existsInDB = true
+	if result.MatchedCount == 0 {
+		if _, err = m.GetServerContent(ctx, database); err != nil {
+			return err
 		}
-	}
-	if !existsInDB {
-		birthdays = append(birthdays, Birthday{
-			ID:   id,
-			Date: date,
-		})
-	}
-
-	if _, err = server_db.UpdateOne(ctx,
-		bson.M{"server": database},
-		bson.D{{"$set", bson.D{{"birthdays", birthdays}}}}); err != nil {
-		return commonerrors.ErrCannotInsertIntoDB
+		return commonerrors.ErrIDNotInDatabase
 	}
 
-	log.Info(fmt.Sprintf("Added Birthday for %s on %s %d\n", id, date.Month().String(), date.Day()))
-	return err
+	log.Info(fmt.Sprintf("Removed Birthday for %s", id))
+	return nil
 }
 
-func GetBirthdaysFromDatabase(database string) (birthdays Birthdays, err error) {
-	serverContent, err1 := getServerContent(database)
+func (m *MongoStore) GetBirthdaysFromDatabase(ctx context.Context, database string) (birthdays Birthdays, err error) {
+	serverContent, err1 := m.GetServerContent(ctx, database)
 	if err1 != nil {
 		err = err1
 		return
@@ -119,110 +228,173 @@ func GetBirthdaysFromDatabase(database string) (birthdays Birthdays, err error)
 	return serverContent.Birthdays, nil
 }
 
-func SetupBirthdayDatabase(database, defaultChannel, timezone, server, interval string) (err error) {
-	server_db := BirthdaysDatabase.Collection(BirthdayDatabaseName)
-	ctx, _ := context.WithTimeout(context.Background(), Timeout)
+func (m *MongoStore) SetupBirthdayDatabase(ctx context.Context, database, defaultChannel, timezone, server string, hour int) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	if _, err = m.collection().UpdateOne(ctx,
+		bson.M{"server": server},
+		bson.M{
+			"$set": bson.M{
+				"channel":  defaultChannel,
+				"timezone": timezone,
+				"time":     hour,
+			},
+			"$setOnInsert": bson.M{
+				"schemaVersion": CurrentSchemaVersion,
+				"birthdays":     []Birthday{},
+			},
+		},
+		options.Update().SetUpsert(true)); err != nil {
+		log.Error(err)
+		return commonerrors.ErrCannotUpdateDB
+	}
 
-	var item ServerKeys
-	if err = server_db.FindOne(ctx, bson.M{"isKeyList": true}).Decode(&item); err != nil {
+	log.Info("Database Setup Done")
+	return nil
+}
+
+func (m *MongoStore) SetServerSetting(ctx context.Context, database, setting string, value interface{}) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	result, err := m.collection().UpdateOne(ctx,
+		bson.M{"server": database},
+		bson.M{"$set": bson.M{setting: value}})
+	if err != nil {
+		return commonerrors.ErrCannotUpdateDB
+	}
+	if result.MatchedCount == 0 {
 		return commonerrors.ErrCannotOpenDatabase
 	}
-	keys := item.Keys
-	for i := range keys {
-		if keys[i] == server {
-			if _, err = server_db.UpdateOne(ctx,
-				bson.M{"server": server},
-				bson.D{{"$set", bson.D{
-					{Key: "channel", Value: defaultChannel},
-					{Key: "timezone", Value: timezone},
-					{Key: "time", Value: interval}}}}); err != nil {
-				return commonerrors.ErrCannotUpdateDB
-			}
-			return
+	return nil
+}
+
+func (m *MongoStore) RecordGreeting(ctx context.Context, database, id string, year int) (recorded bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	// The unique index means only the first insert for a user and year succeeds
+	if _, err = m.database.Collection(GreetingsName).InsertOne(ctx, bson.M{
+		"server": database,
+		"user":   id,
+		"year":   year,
+	}); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
 		}
+		return false, commonerrors.ErrCannotInsertIntoDB
 	}
+	return true, nil
+}
 
-	keys = append(keys, server)
+func (m *MongoStore) ForgetGreeting(ctx context.Context, database, id string, year int) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
 
-	if _, err = server_db.UpdateOne(ctx,
-		bson.M{"isKeyList": true},
-		bson.D{{"$set", bson.D{{"keys", keys}}}}); err != nil {
-		return commonerrors.ErrCannotInsertIntoDB
+	if _, err = m.database.Collection(GreetingsName).DeleteOne(ctx, bson.M{
+		"server": database,
+		"user":   id,
+		"year":   year,
+	}); err != nil {
+		return commonerrors.ErrCannotUpdateDB
 	}
+	return nil
+}
 
-	_, err = server_db.InsertOne(ctx, bson.D{
-		{Key: "server", Value: server},
-		{Key: "channel", Value: defaultChannel},
-		{Key: "timezone", Value: timezone},
-		{Key: "time", Value: interval},
-		{Key: "birthdays", Value: []Birthday{}},
-	})
-
-	if err != nil {
-		log.Error(err)
-		return commonerrors.ErrCannotOpenDatabase
+func (m *MongoStore) RecordReminder(ctx context.Context, database, id string, day time.Time) (recorded bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	// The unique index means only the first insert for a user and day succeeds
+	if _, err = m.database.Collection(RemindersName).InsertOne(ctx, bson.M{
+		"server": database,
+		"user":   id,
+		"day":    day.Format(dayFormat),
+	}); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, commonerrors.ErrCannotInsertIntoDB
 	}
-
-	log.Info("Database Setup Done")
-	return nil
+	return true, nil
 }
 
-func GetDefaultChannel(database string) (channel string, err error) {
-	serverContent, err1 := getServerContent(database)
-	if err1 != nil {
-		err = err1
-		return
+func (m *MongoStore) ForgetReminder(ctx context.Context, database, id string, day time.Time) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	if _, err = m.database.Collection(RemindersName).DeleteOne(ctx, bson.M{
+		"server": database,
+		"user":   id,
+		"day":    day.Format(dayFormat),
+	}); err != nil {
+		return commonerrors.ErrCannotUpdateDB
 	}
-	return serverContent.Channel, nil
+	return nil
 }
 
-func GetServerID(database string) (server string, err error) {
-	serverContent, err1 := getServerContent(database)
-	if err1 != nil {
-		err = err1
-		return
+func (m *MongoStore) AddRoleGrant(ctx context.Context, database string, grant RoleGrant) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	if _, err = m.database.Collection(RoleGrantsName).UpdateOne(ctx,
+		bson.M{"server": database, "user": grant.ID},
+		bson.M{"$set": bson.M{"role": grant.Role, "expires": grant.Expires}},
+		options.Update().SetUpsert(true)); err != nil {
+		return commonerrors.ErrCannotInsertIntoDB
 	}
-	return serverContent.Server, nil
+	return nil
 }
 
-func GetTimezone(database string) (tz string, err error) {
-	serverContent, err1 := getServerContent(database)
-	if err1 != nil {
-		err = err1
-		return
+func (m *MongoStore) GetRoleGrants(ctx context.Context, database string) (grants []RoleGrant, err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	cursor, err := m.database.Collection(RoleGrantsName).Find(ctx, bson.M{"server": database})
+	if err != nil {
+		return nil, commonerrors.ErrCannotOpenDatabase
+	}
+	if err = cursor.All(ctx, &grants); err != nil {
+		return nil, commonerrors.ErrCannotParse
 	}
-	return serverContent.Timezone, nil
+	return grants, nil
 }
 
-func GetTimeInterval(database string) (interval string, err error) {
-	serverContent, err1 := getServerContent(database)
-	if err1 != nil {
-		err = err1
-		return
+func (m *MongoStore) RemoveRoleGrant(ctx context.Context, database, id string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	if _, err = m.database.Collection(RoleGrantsName).DeleteOne(ctx, bson.M{"server": database, "user": id}); err != nil {
+		return commonerrors.ErrCannotUpdateDB
 	}
-	return serverContent.Time, nil
+	return nil
 }
 
-func getServerContent(database string) (value ServerContent, err error) {
-	server_db := BirthdaysDatabase.Collection(BirthdayDatabaseName)
-	ctx, _ := context.WithTimeout(context.Background(), Timeout)
+func (m *MongoStore) GetServerContent(ctx context.Context, database string) (value ServerContent, err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
 
-	if err1 := server_db.FindOne(ctx, bson.M{"server": database}).Decode(&value); err1 != nil {
+	if err1 := m.collection().FindOne(ctx, bson.M{"server": database}).Decode(&value); err1 != nil {
 		err = commonerrors.ErrCannotOpenDatabase
 		return
 	}
 	return
 }
 
-func GetServerKeys() (keys []string, err error) {
-	server_db := BirthdaysDatabase.Collection(BirthdayDatabaseName)
-	ctx, _ := context.WithTimeout(context.Background(), Timeout)
+func (m *MongoStore) GetServerKeys(ctx context.Context) (keys []string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
 
-	var item ServerKeys
-	if err1 := server_db.FindOne(ctx, bson.M{"isKeyList": true}).Decode(&item); err1 != nil {
+	servers, err1 := m.collection().Distinct(ctx, "server", bson.M{"server": bson.M{"$exists": true}})
+	if err1 != nil {
 		err = commonerrors.ErrCannotOpenDatabase
 		return
 	}
-	keys = item.Keys
+	for _, server := range servers {
+		if key, ok := server.(string); ok {
+			keys = append(keys, key)
+		}
+	}
 	return
 }
@@ -0,0 +1,185 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestMongoStore connects to the MongoDB at DISCORD_BOT_MONGODB_URI using a database of its own
+// that is dropped by the returned cleanup function, skipping the test if no URI is set.
+func newTestMongoStore(t *testing.T) (store *MongoStore, cleanup func()) {
+	uri := os.Getenv("DISCORD_BOT_MONGODB_URI")
+	if uri == "" {
+		t.Skip("DISCORD_BOT_MONGODB_URI is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connecting to MongoDB: %s", err)
+	}
+	database := client.Database(fmt.Sprintf("birthday_test_%d", time.Now().UnixNano()))
+	cleanup = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = database.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	}
+
+	store, err = NewMongoStore(ctx, database)
+	if err != nil {
+		cleanup()
+		t.Fatalf("creating store: %s", err)
+	}
+	return store, cleanup
+}
+
+// newTestBoltStore creates a bolt store in a temporary directory that is removed by the returned cleanup
+// function.
+func newTestBoltStore(t *testing.T) (store *BoltStore, cleanup func()) {
+	dir, err := ioutil.TempDir("", "birthday")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err = NewBoltStore(filepath.Join(dir, "birthdays.db"))
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return store, func() {
+		_ = store.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// testStores are the stores the store tests are run against. MongoDB is skipped unless
+// DISCORD_BOT_MONGODB_URI is set.
+var testStores = map[string]func(t *testing.T) (Store, func()){
+	StoreMemory: func(t *testing.T) (Store, func()) {
+		return NewMemoryStore(), func() {}
+	},
+	StoreBolt: func(t *testing.T) (Store, func()) {
+		return newTestBoltStore(t)
+	},
+	StoreMongoDB: func(t *testing.T) (Store, func()) {
+		return newTestMongoStore(t)
+	},
+}
+
+func TestConcurrentAdds(t *testing.T) {
+	for name, newStore := range testStores {
+		t.Run(name, func(t *testing.T) {
+			store, cleanup := newStore(t)
+			defer cleanup()
+			testConcurrentAdds(t, store)
+		})
+	}
+}
+
+// testConcurrentAdds checks no birthdays are lost when they are added at the same time.
+func testConcurrentAdds(t *testing.T, store Store) {
+	ctx := context.Background()
+	const server, users = "guild", 50
+	if err := store.SetupBirthdayDatabase(ctx, server, "channel", "UTC", server, 9); err != nil {
+		t.Fatalf("setup: %s", err)
+	}
+	date := time.Date(2000, time.June, 5, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*users)
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			errs <- store.AddBirthdayToDatabase(ctx, server, id, date)
+		}(fmt.Sprintf("user%d", i))
+	}
+	// Racing adds for the same user must leave a single entry with one of the dates
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(day int) {
+			defer wg.Done()
+			errs <- store.AddBirthdayToDatabase(ctx, server, "same", date.AddDate(0, 0, day))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("add: %s", err)
+		}
+	}
+
+	birthdays, err := store.GetBirthdaysFromDatabase(ctx, server)
+	if err != nil {
+		t.Fatalf("getting birthdays: %s", err)
+	}
+	counts := map[string]int{}
+	for _, birthday := range birthdays {
+		counts[birthday.ID]++
+	}
+	for i := 0; i < users; i++ {
+		if id := fmt.Sprintf("user%d", i); counts[id] != 1 {
+			t.Errorf("expected 1 birthday for %s, got %d", id, counts[id])
+		}
+	}
+	if counts["same"] != 1 {
+		t.Errorf("expected 1 birthday for racing adds of the same user, got %d", counts["same"])
+	}
+	if len(birthdays) != users+1 {
+		t.Errorf("expected %d birthdays, got %d", users+1, len(birthdays))
+	}
+}
+
+func TestRecordReminder(t *testing.T) {
+	for name, newStore := range testStores {
+		t.Run(name, func(t *testing.T) {
+			store, cleanup := newStore(t)
+			defer cleanup()
+			testRecordReminder(t, store)
+		})
+	}
+}
+
+// testRecordReminder checks each reminder is only recorded once until it is forgotten.
+func testRecordReminder(t *testing.T, store Store) {
+	ctx := context.Background()
+	day := time.Date(2026, time.June, 4, 9, 0, 0, 0, time.UTC)
+	for _, step := range []struct {
+		id     string
+		day    time.Time
+		forget bool
+		want   bool
+	}{
+		{id: "1", day: day, want: true},
+		{id: "1", day: day, want: false},
+		{id: "1", day: day.AddDate(0, 0, -6), want: true},
+		{id: "2", day: day, want: true},
+		{id: "1", day: day, forget: true},
+		{id: "1", day: day, want: true},
+	} {
+		if step.forget {
+			if err := store.ForgetReminder(ctx, "guild", step.id, step.day); err != nil {
+				t.Fatalf("forget: %s", err)
+			}
+			continue
+		}
+		recorded, err := store.RecordReminder(ctx, "guild", step.id, step.day)
+		if err != nil {
+			t.Fatalf("record: %s", err)
+		}
+		if recorded != step.want {
+			t.Errorf("expected recording the reminder for %s on %s to return %t", step.id, step.day.Format(dayFormat), step.want)
+		}
+	}
+}
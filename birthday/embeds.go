@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/joshjennings98/discord-bot/platform"
+	"github.com/joshjennings98/discord-bot/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const embedColour = 0xf47fff
+
+// EmbedReply is a message sent as an embed. Fallback is sent as plain text instead wherever the bot isn't
+// allowed to send embeds, so it should say the same thing as the embed.
+type EmbedReply struct {
+	// Content is sent alongside the embed. Mentions only notify users when they are in the content.
+	Content  string
+	Embed    *platform.Embed
+	Fallback string
+}
+
+// ReplyEmbed sends an embed in response to a command, see Reply.
+func (d *DiscordBot) ReplyEmbed(command *Command, reply EmbedReply) {
+	if command.Interaction == nil {
+		d.send(command.Channel, command.Server, reply)
+		return
+	}
+	log.Info(fmt.Sprintf("Responding to interaction in channel %s on server %s with embed: '%s'", command.Channel, command.Server, reply.Fallback))
+	d.respond(command, reply.Content, []*platform.Embed{reply.Embed})
+}
+
+// send sends an embed to a channel, or its fallback if the bot can't send embeds there. It returns an
+// error if neither could be sent.
+func (d *DiscordBot) send(channel, server string, reply EmbedReply) error {
+	if !platform.CanEmbed(d.directory, channel) {
+		return platform.LogAndSend(d.messenger, channel, server, reply.Fallback, nil)
+	}
+	log.Info(fmt.Sprintf("Sending embed to channel %s on server %s: '%s'", channel, server, reply.Fallback))
+	if err := d.messenger.SendEmbed(channel, reply.Content, reply.Embed); err != nil {
+		return platform.LogAndSend(d.messenger, channel, server, reply.Fallback, err)
+	}
+	return nil
+}
+
+// member looks up a member of the server, returning nil if they can't be found.
+func (d *DiscordBot) member(server, id string) *platform.Member {
+	member, err := d.directory.Member(server, id)
+	if err != nil {
+		return nil
+	}
+	return member
+}
+
+// birthdayEmbed shows a members birthday with a countdown to the next one, which Discord displays
+// relative to the time it is read.
+func (d *DiscordBot) birthdayEmbed(server, id, title string, birthday, next time.Time) *platform.Embed {
+	embed := &platform.Embed{
+		Title:       title,
+		Description: fmt.Sprintf("<@%s>", id),
+		Colour:      embedColour,
+		Fields: []*platform.EmbedField{
+			{Name: "Date", Value: fmt.Sprintf("%s %s", birthday.Month(), utils.AddNumSuffix(birthday.Day())), Inline: true},
+			{Name: "Countdown", Value: fmt.Sprintf("<t:%d:R>", next.Unix()), Inline: true},
+		},
+	}
+	if member := d.member(server, id); member != nil {
+		embed.ThumbnailURL = member.AvatarURL
+	}
+	return embed
+}
+
+// greetingEmbed is the birthday message. The user is mentioned in the content as well so they are notified.
+func (d *DiscordBot) greetingEmbed(server, id, message string, belated bool) EmbedReply {
+	title := "Happy Birthday! :birthday:"
+	if belated {
+		title = "Happy belated Birthday! :birthday:"
+	}
+	embed := &platform.Embed{
+		Title:       title,
+		Description: message,
+		Colour:      embedColour,
+	}
+	if member := d.member(server, id); member != nil {
+		embed.ThumbnailURL = member.AvatarURL
+	}
+	return EmbedReply{Content: fmt.Sprintf("<@%s>", id), Embed: embed, Fallback: message}
+}
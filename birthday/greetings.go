@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/joshjennings98/discord-bot/platform"
+)
+
+const (
+	maxGreetingTemplates      = 10
+	maxGreetingTemplateLength = 150
+	maxMessageLength          = 2000
+)
+
+// DefaultGreetingTemplate is used for servers that haven't added any greeting templates of their own.
+const DefaultGreetingTemplate = "Happy {{if .Belated}}belated {{end}}Birthday {{.Mention}}!!! {{if .Belated}}Sorry I missed it {{end}}:partying_face:"
+
+// GreetingData is the data greeting templates are executed with, e.g. `Happy birthday {{.Name}}!`.
+type GreetingData struct {
+	Mention string
+	Name    string
+	// Age is the age the user is turning, or 0 if their birth year isn't known. Only the day and
+	// month of birthdays are stored at the moment so it is always 0 for now.
+	Age int
+	// DaysUntil is 0 on the birthday and negative for belated greetings
+	DaysUntil int
+	Belated   bool
+}
+
+var greetingRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// RenderGreeting executes a greeting template.
+func RenderGreeting(text string, data GreetingData) (message string, err error) {
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("template is empty")
+	}
+	if len(text) > maxGreetingTemplateLength {
+		return "", fmt.Errorf("template must be at most %d characters", maxGreetingTemplateLength)
+	}
+	tmpl, err := template.New("greeting").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var rendered strings.Builder
+	if err = tmpl.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+	message = strings.TrimSpace(rendered.String())
+	if message == "" {
+		return "", fmt.Errorf("template renders an empty message")
+	}
+	if len(message) > maxMessageLength {
+		return "", fmt.Errorf("template renders a message longer than %d characters", maxMessageLength)
+	}
+	return message, nil
+}
+
+// ValidateGreetingTemplate checks a greeting template renders for both a normal and a belated greeting,
+// so mistakes are caught when it is added rather than on someones birthday.
+func ValidateGreetingTemplate(text string) error {
+	sample := GreetingData{Mention: "<@0>", Name: "name", Age: 30}
+	if _, err := RenderGreeting(text, sample); err != nil {
+		return err
+	}
+	sample.Belated = true
+	sample.DaysUntil = -1
+	_, err := RenderGreeting(text, sample)
+	return err
+}
+
+// pickGreetingTemplate picks one of the servers templates at random, or the default template if the
+// server doesn't have any.
+func pickGreetingTemplate(templates []string) string {
+	if len(templates) == 0 {
+		return DefaultGreetingTemplate
+	}
+	greetingRand.Lock()
+	defer greetingRand.Unlock()
+	return templates[greetingRand.Intn(len(templates))]
+}
+
+// greeting renders a random greeting from the servers templates, falling back to the default template
+// if the chosen one fails.
+func (d *DiscordBot) greeting(serverContent ServerContent, data GreetingData) string {
+	message, err := RenderGreeting(pickGreetingTemplate(serverContent.GreetingTemplates), data)
+	if err != nil {
+		message, _ = RenderGreeting(DefaultGreetingTemplate, data)
+	}
+	return message
+}
+
+func (d *DiscordBot) greetingData(server, id string) GreetingData {
+	data := GreetingData{Mention: fmt.Sprintf("<@%s>", id)}
+	data.Name = platform.DisplayName(d.directory, server, id)
+	if data.Name == "" {
+		data.Name = data.Mention
+	}
+	return data
+}
+
+// daysBetween returns the number of calendar days from one date to another.
+func daysBetween(from, to time.Time) int {
+	fromDate := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDate := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDate.Sub(fromDate).Hours() / 24)
+}
+
+func (d *DiscordBot) Greeting(command *Command) {
+	serverContent, err := d.store.GetServerContent(command.Context(), command.Database)
+	if err != nil {
+		message := fmt.Sprintf("Error getting server settings: %s. Has `%s setup` been run?", err.Error(), command.Prefix)
+		d.Reply(command, message, err)
+		return
+	}
+	templates := serverContent.GreetingTemplates
+	text := command.Arg("template")
+
+	switch command.Arg("action") {
+	case "add":
+		if err = ValidateGreetingTemplate(text); err != nil {
+			message := fmt.Sprintf("Invalid template: %s.", err.Error())
+			d.Reply(command, message, nil)
+			return
+		}
+		if len(templates) >= maxGreetingTemplates {
+			message := fmt.Sprintf("At most %d greeting templates can be added, remove one first.", maxGreetingTemplates)
+			d.Reply(command, message, nil)
+			return
+		}
+		d.saveGreetingTemplates(command, append(templates, text), fmt.Sprintf("Added greeting template %d.", len(templates)+1))
+	case "remove":
+		i, err := greetingTemplateIndex(templates, text)
+		if err != nil {
+			d.Reply(command, fmt.Sprintf("%s.", err.Error()), nil)
+			return
+		}
+		remaining := append(append([]string{}, templates[:i]...), templates[i+1:]...)
+		d.saveGreetingTemplates(command, remaining, fmt.Sprintf("Removed greeting template %d.", i+1))
+	case "preview":
+		if text == "" {
+			text = pickGreetingTemplate(templates)
+		} else if i, err := greetingTemplateIndex(templates, text); err == nil {
+			text = templates[i]
+		}
+		message, err := RenderGreeting(text, d.greetingData(command.Server, command.Author))
+		if err != nil {
+			message = fmt.Sprintf("Invalid template: %s.", err.Error())
+		}
+		d.Reply(command, message, nil)
+	default:
+		d.Reply(command, listGreetingTemplates(templates), nil)
+	}
+}
+
+func (d *DiscordBot) saveGreetingTemplates(command *Command, templates []string, message string) {
+	err := d.store.SetServerSetting(command.Context(), command.Database, SettingGreetingTemplates, templates)
+	if err != nil {
+		message = fmt.Sprintf("Error updating server settings: %s.", err.Error())
+	}
+	d.Reply(command, message, err)
+}
+
+// greetingTemplateIndex parses the 1-based number of a template as shown by list.
+func greetingTemplateIndex(templates []string, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > len(templates) {
+		return 0, fmt.Errorf("'%s' is not a greeting template number, use `list` to see them", value)
+	}
+	return n - 1, nil
+}
+
+func listGreetingTemplates(templates []string) string {
+	if len(templates) == 0 {
+		return fmt.Sprintf("No greeting templates have been added, the default is used:\n`%s`", DefaultGreetingTemplate)
+	}
+	var list strings.Builder
+	list.WriteString("**Greeting templates:**")
+	for i, text := range templates {
+		fmt.Fprintf(&list, "\n%d. `%s`", i+1, text)
+	}
+	return list.String()
+}
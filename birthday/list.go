@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshjennings98/discord-bot/platform"
+	"github.com/joshjennings98/discord-bot/utils"
+)
+
+// maxPageLength leaves room in a message for the page title.
+const maxPageLength = maxMessageLength - 100
+
+// ListBirthdays replies with every birthday in the order they are coming up, grouped by month. Servers
+// with more birthdays than fit in one message get a message per page.
+func (d *DiscordBot) ListBirthdays(command *Command) {
+	birthdays, err := d.store.GetBirthdaysFromDatabase(command.Context(), command.Database)
+	if err != nil {
+		message := fmt.Sprintf("Error retrieving birthdays from database: %s.", err.Error())
+		d.Reply(command, message, err)
+		return
+	}
+	if len(birthdays) == 0 {
+		message := "There are no birthdays in the database."
+		d.Reply(command, message, nil)
+		return
+	}
+	sort.Sort(birthdays) // sort by date
+
+	// Start from today in the servers timezone, wrapping the birthdays earlier in the year to the end
+	now := d.localNow(command)
+	start := len(birthdays)
+	for i, birthday := range birthdays {
+		if nextOccurrence(birthday.Date, now).Year() == now.Year() {
+			start = i
+			break
+		}
+	}
+	upcoming := append(append(Birthdays{}, birthdays[start:]...), birthdays[:start]...)
+
+	pages := listPages(upcoming, now)
+	for i, page := range pages {
+		title := "Birthdays :calendar:"
+		if len(pages) > 1 {
+			title = fmt.Sprintf("Birthdays (%d/%d) :calendar:", i+1, len(pages))
+		}
+		embed := &platform.Embed{
+			Title:       title,
+			Description: page,
+			Colour:      embedColour,
+		}
+		d.ReplyEmbed(command, EmbedReply{Embed: embed, Fallback: fmt.Sprintf("**%s**\n%s", title, page)})
+	}
+}
+
+// listPages splits the birthdays into pages of at most maxPageLength characters, with a heading for each
+// month that is repeated if a month is split across pages.
+func listPages(upcoming Birthdays, now time.Time) (pages []string) {
+	var page strings.Builder
+	var month string
+	for _, birthday := range upcoming {
+		date := nextOccurrence(birthday.Date, now)
+		line := fmt.Sprintf("%s - <@%s>", utils.AddNumSuffix(date.Day()), birthday.ID)
+		heading := fmt.Sprintf("**%s**", date.Format("January 2006"))
+		text := line
+		if heading != month {
+			text = heading + "\n" + line
+		}
+		if page.Len() > 0 && page.Len()+len(text)+2 > maxPageLength {
+			pages = append(pages, page.String())
+			page.Reset()
+			if heading == month {
+				text = heading + " (continued)\n" + line
+			}
+		}
+		if page.Len() > 0 {
+			if heading != month {
+				page.WriteString("\n")
+			}
+			page.WriteString("\n")
+		}
+		page.WriteString(text)
+		month = heading
+	}
+	return append(pages, page.String())
+}
@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	commonerrors "github.com/joshjennings98/discord-bot/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// MemoryStore is a Store that keeps everything in memory. It mirrors the behaviour of
+// MongoStore and is intended for tests and local development.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	servers    map[string]*ServerContent
+	greetings  map[string]bool
+	reminders  map[string]bool
+	roleGrants map[string]map[string]RoleGrant
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		servers:    map[string]*ServerContent{},
+		greetings:  map[string]bool{},
+		reminders:  map[string]bool{},
+		roleGrants: map[string]map[string]RoleGrant{},
+	}
+}
+
+func (m *MemoryStore) CheckForBirthdaysInDatabase(ctx context.Context, database string, t time.Time) (birthdays []string, err error) {
+	item, err := m.GetServerContent(ctx, database)
+	if err != nil {
+		return
+	}
+
+	for _, birthdayItem := range item.Birthdays {
+		if IsBirthday(birthdayItem.Date, t) {
+			birthdays = append(birthdays, birthdayItem.ID)
+		}
+	}
+	return
+}
+
+func (m *MemoryStore) CheckForUsersBirthdayInDatabase(ctx context.Context, database, userID string) (birthday time.Time, err error) {
+	item, err := m.GetServerContent(ctx, database)
+	if err != nil {
+		return
+	}
+
+	for _, birthdayItem := range item.Birthdays {
+		if birthdayItem.ID == userID {
+			birthday = birthdayItem.Date
+			return
+		}
+	}
+	birthday = time.Unix(0, 0)
+	return
+}
+
+func (m *MemoryStore) AddBirthdayToDatabase(ctx context.Context, database, id string, date time.Time) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.servers[database]
+	if !ok {
+		return commonerrors.ErrCannotOpenDatabase
+	}
+
+	existsInDB := false
+	for i := range item.Birthdays {
+		if item.Birthdays[i].ID == id {
+			item.Birthdays[i].Date = date
+			existsInDB = true
+		}
+	}
+	if !existsInDB {
+		item.Birthdays = append(item.Birthdays, Birthday{
+			ID:   id,
+			Date: date,
+		})
+	}
+
+	log.Info(fmt.Sprintf("Added Birthday for %s on %s %d\n", id, date.Month().String(), date.Day()))
+	return
+}
+
+func (m *MemoryStore) RemoveBirthdayFromDatabase(ctx context.Context, database, id string) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.servers[database]
+	if !ok {
+		return commonerrors.ErrCannotOpenDatabase
+	}
+	for i := range item.Birthdays {
+		if item.Birthdays[i].ID == id {
+			item.Birthdays = append(item.Birthdays[:i], item.Birthdays[i+1:]...)
+			log.Info(fmt.Sprintf("Removed Birthday for %s", id))
+			return nil
+		}
+	}
+	return commonerrors.ErrIDNotInDatabase
+}
+
+func (m *MemoryStore) GetBirthdaysFromDatabase(ctx context.Context, database string) (birthdays Birthdays, err error) {
+	serverContent, err1 := m.GetServerContent(ctx, database)
+	if err1 != nil {
+		err = err1
+		return
+	}
+	return serverContent.Birthdays, nil
+}
+
+func (m *MemoryStore) SetupBirthdayDatabase(ctx context.Context, database, defaultChannel, timezone, server string, hour int) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if item, ok := m.servers[server]; ok {
+		item.Channel = defaultChannel
+		item.Timezone = timezone
+		item.Time = hour
+		return
+	}
+
+	m.servers[server] = &ServerContent{
+		SchemaVersion: CurrentSchemaVersion,
+		Server:        server,
+		Channel:       defaultChannel,
+		Timezone:      timezone,
+		Time:          hour,
+		Birthdays:     []Birthday{},
+	}
+
+	log.Info("Database Setup Done")
+	return nil
+}
+
+func (m *MemoryStore) SetServerSetting(ctx context.Context, database, setting string, value interface{}) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.servers[database]
+	if !ok {
+		return commonerrors.ErrCannotOpenDatabase
+	}
+	document, err := json.Marshal(item)
+	if err != nil {
+		return commonerrors.ErrCannotParse
+	}
+	document, err = setJSONField(document, setting, value)
+	if err != nil {
+		return err
+	}
+	var updated ServerContent
+	if err = json.Unmarshal(document, &updated); err != nil {
+		return commonerrors.ErrCannotParse
+	}
+	updated.Birthdays = item.Birthdays
+	*item = updated
+	return nil
+}
+
+func (m *MemoryStore) RecordGreeting(ctx context.Context, database, id string, year int) (recorded bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := fmt.Sprintf("%s/%d/%s", database, year, id)
+	if m.greetings[key] {
+		return false, nil
+	}
+	m.greetings[key] = true
+	return true, nil
+}
+
+func (m *MemoryStore) ForgetGreeting(ctx context.Context, database, id string, year int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.greetings, fmt.Sprintf("%s/%d/%s", database, year, id))
+	return nil
+}
+
+func (m *MemoryStore) RecordReminder(ctx context.Context, database, id string, day time.Time) (recorded bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := fmt.Sprintf("%s/%s/%s", database, day.Format(dayFormat), id)
+	if m.reminders[key] {
+		return false, nil
+	}
+	m.reminders[key] = true
+	return true, nil
+}
+
+func (m *MemoryStore) ForgetReminder(ctx context.Context, database, id string, day time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.reminders, fmt.Sprintf("%s/%s/%s", database, day.Format(dayFormat), id))
+	return nil
+}
+
+func (m *MemoryStore) AddRoleGrant(ctx context.Context, database string, grant RoleGrant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.roleGrants[database] == nil {
+		m.roleGrants[database] = map[string]RoleGrant{}
+	}
+	m.roleGrants[database][grant.ID] = grant
+	return nil
+}
+
+func (m *MemoryStore) GetRoleGrants(ctx context.Context, database string) (grants []RoleGrant, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, grant := range m.roleGrants[database] {
+		grants = append(grants, grant)
+	}
+	return
+}
+
+func (m *MemoryStore) RemoveRoleGrant(ctx context.Context, database, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.roleGrants[database], id)
+	return nil
+}
+
+func (m *MemoryStore) GetServerContent(ctx context.Context, database string) (value ServerContent, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, ok := m.servers[database]
+	if !ok {
+		err = commonerrors.ErrCannotOpenDatabase
+		return
+	}
+	value = *item
+	value.Birthdays = append(Birthdays{}, item.Birthdays...)
+	return
+}
+
+func (m *MemoryStore) GetServerKeys(ctx context.Context) (keys []string, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for key := range m.servers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
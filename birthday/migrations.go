@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	commonerrors "github.com/joshjennings98/discord-bot/errors"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CurrentSchemaVersion is the schema version of server documents written by this version of the bot.
+// It must match the version of the last entry in Migrations.
+const CurrentSchemaVersion = 2
+
+// Migration upgrades a raw server document to Version. Migrations work on raw documents rather
+// than ServerContent so that they keep working as the Go types change.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(document bson.M) error
+}
+
+// Migrations are applied in order to every server document whose schema version is lower than
+// their own. Documents without a schema version are treated as version 0.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "initialise missing birthday lists",
+		Up: func(document bson.M) error {
+			if birthdays, ok := document["birthdays"]; !ok || birthdays == nil {
+				document["birthdays"] = bson.A{}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "store the greeting hour as a number",
+		Up: func(document bson.M) error {
+			value, ok := document["time"].(string)
+			if !ok {
+				return nil // not set or already a number
+			}
+			hour, err := strconv.Atoi(value)
+			if err != nil || hour < 0 || hour > 23 {
+				return fmt.Errorf("invalid hour '%s'", value)
+			}
+			document["time"] = int32(hour)
+			return nil
+		},
+	},
+}
+
+type MigrationResult struct {
+	Server string
+	From   int
+	To     int
+	Steps  []string
+}
+
+func (r MigrationResult) String() string {
+	return fmt.Sprintf("server %s: version %d -> %d %v", r.Server, r.From, r.To, r.Steps)
+}
+
+// Migrator is implemented by stores that can migrate their stored documents between schema versions.
+type Migrator interface {
+	Migrate(ctx context.Context, dryRun bool) (results []MigrationResult, err error)
+}
+
+func schemaVersion(document bson.M) int {
+	switch v := document["schemaVersion"].(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// MigrateDocument applies every pending migration to document in place and returns the steps run.
+func MigrateDocument(document bson.M) (steps []string, err error) {
+	version := schemaVersion(document)
+	for _, migration := range Migrations {
+		if migration.Version <= version {
+			continue
+		}
+		if err = migration.Up(document); err != nil {
+			return steps, fmt.Errorf("migration to version %d failed: %w", migration.Version, err)
+		}
+		steps = append(steps, migration.Description)
+		version = migration.Version
+	}
+	document["schemaVersion"] = version
+	return
+}
+
+// Migrate upgrades every server document to CurrentSchemaVersion. With dryRun set nothing is written
+// and the returned results describe what would have been done. Each document is read and written
+// with its own timeout so large collections aren't limited to a single Timeout.
+func (m *MongoStore) Migrate(ctx context.Context, dryRun bool) (results []MigrationResult, err error) {
+	findCtx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	cursor, err := m.collection().Find(findCtx, bson.M{
+		"server": bson.M{"$exists": true},
+		"$or": bson.A{
+			bson.M{"schemaVersion": bson.M{"$exists": false}},
+			bson.M{"schemaVersion": bson.M{"$lt": CurrentSchemaVersion}},
+		},
+	})
+	if err != nil {
+		return nil, commonerrors.ErrCannotOpenDatabase
+	}
+	defer cursor.Close(context.Background())
+
+	for {
+		documentCtx, cancel := context.WithTimeout(ctx, Timeout)
+		result, ok, err := m.migrateNext(documentCtx, cursor, dryRun)
+		cancel()
+		if err != nil {
+			return results, err
+		}
+		if !ok {
+			return results, nil
+		}
+		results = append(results, result)
+	}
+}
+
+// migrateNext migrates the next document from the cursor, returning false once there are none left.
+func (m *MongoStore) migrateNext(ctx context.Context, cursor *mongo.Cursor, dryRun bool) (result MigrationResult, ok bool, err error) {
+	if !cursor.Next(ctx) {
+		if cursor.Err() != nil {
+			return result, false, commonerrors.ErrCannotOpenDatabase
+		}
+		return result, false, nil
+	}
+	var document bson.M
+	if err = cursor.Decode(&document); err != nil {
+		return result, false, commonerrors.ErrCannotParse
+	}
+
+	from := schemaVersion(document)
+	filter := bson.M{"_id": document["_id"], "schemaVersion": document["schemaVersion"]}
+	if _, ok := document["schemaVersion"]; !ok {
+		filter["schemaVersion"] = bson.M{"$exists": false}
+	}
+	steps, err := MigrateDocument(document)
+	if err != nil {
+		return result, false, err
+	}
+	result = MigrationResult{
+		Server: fmt.Sprintf("%v", document["server"]),
+		From:   from,
+		To:     schemaVersion(document),
+		Steps:  steps,
+	}
+	if !dryRun {
+		// Only replace the document if it hasn't been changed since we read it
+		updated, err := m.collection().ReplaceOne(ctx, filter, document)
+		if err != nil {
+			return result, false, commonerrors.ErrCannotUpdateDB
+		}
+		if updated.MatchedCount == 0 {
+			return result, false, fmt.Errorf("%w: server %s changed during migration", commonerrors.ErrCannotUpdateDB, result.Server)
+		}
+		log.Info(fmt.Sprintf("Migrated %s", result))
+	}
+	return result, true, nil
+}
+
+// Migrate upgrades every server document to CurrentSchemaVersion, see MongoStore.Migrate. The documents
+// are migrated in a single transaction so either all of them are migrated or none are.
+func (b *BoltStore) Migrate(ctx context.Context, dryRun bool) (results []MigrationResult, err error) {
+	migrate := func(tx *bolt.Tx) error {
+		servers := tx.Bucket(serversBucket)
+		updated := map[string][]byte{}
+		err := servers.ForEach(func(k, v []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var document bson.M
+			if err := json.Unmarshal(v, &document); err != nil {
+				return commonerrors.ErrCannotParse
+			}
+			from := schemaVersion(document)
+			if from >= CurrentSchemaVersion {
+				return nil
+			}
+			steps, err := MigrateDocument(document)
+			if err != nil {
+				return err
+			}
+			// Birthdays are kept in their own bucket rather than in the server document
+			delete(document, "birthdays")
+			value, err := json.Marshal(document)
+			if err != nil {
+				return commonerrors.ErrCannotParse
+			}
+			updated[string(k)] = value
+			results = append(results, MigrationResult{Server: string(k), From: from, To: schemaVersion(document), Steps: steps})
+			return nil
+		})
+		if err != nil || dryRun {
+			return err
+		}
+		// Buckets can't be changed while iterating over them so the documents are written afterwards
+		for server, value := range updated {
+			if err := servers.Put([]byte(server), value); err != nil {
+				return commonerrors.ErrCannotUpdateDB
+			}
+		}
+		return nil
+	}
+
+	if dryRun {
+		err = b.db.View(migrate)
+	} else {
+		err = b.db.Update(migrate)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		for _, result := range results {
+			log.Info(fmt.Sprintf("Migrated %s", result))
+		}
+	}
+	return results, nil
+}
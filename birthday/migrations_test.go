@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMigrateDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		document bson.M
+		want     bson.M
+		steps    []string
+		err      bool
+	}{
+		{
+			name:     "unversioned",
+			document: bson.M{"server": "1", "time": "9"},
+			want:     bson.M{"server": "1", "time": int32(9), "birthdays": bson.A{}, "schemaVersion": 2},
+			steps:    []string{"initialise missing birthday lists", "store the greeting hour as a number"},
+		},
+		{
+			name:     "version 1",
+			document: bson.M{"server": "1", "time": "0", "birthdays": bson.A{}, "schemaVersion": int32(1)},
+			want:     bson.M{"server": "1", "time": int32(0), "birthdays": bson.A{}, "schemaVersion": 2},
+			steps:    []string{"store the greeting hour as a number"},
+		},
+		{
+			name:     "up to date",
+			document: bson.M{"server": "1", "time": int32(9), "birthdays": bson.A{}, "schemaVersion": int32(2)},
+			want:     bson.M{"server": "1", "time": int32(9), "birthdays": bson.A{}, "schemaVersion": 2},
+		},
+		{
+			name:     "invalid hour",
+			document: bson.M{"server": "1", "time": "25", "schemaVersion": int32(1)},
+			err:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			steps, err := MigrateDocument(test.document)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", test.document)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(steps, test.steps) {
+				t.Errorf("expected steps %q, got %q", test.steps, steps)
+			}
+			if !reflect.DeepEqual(test.document, test.want) {
+				t.Errorf("expected %v, got %v", test.want, test.document)
+			}
+		})
+	}
+}
+
+func TestBoltMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "birthday")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewBoltStore(filepath.Join(dir, "birthdays.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	// A server set up before the hour was stored as a number
+	legacy, _ := json.Marshal(map[string]interface{}{"server": "1", "channel": "2", "timezone": "UTC", "time": "9", "schemaVersion": 1})
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.Bucket(birthdaysBucket).CreateBucket([]byte("1")); err != nil {
+			return err
+		}
+		return tx.Bucket(serversBucket).Put([]byte("1"), legacy)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.SetupBirthdayDatabase(ctx, "3", "4", "UTC", "3", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := store.Migrate(ctx, true)
+	if err != nil {
+		t.Fatalf("dry run: %s", err)
+	}
+	var output []string
+	for _, result := range results {
+		output = append(output, result.String())
+	}
+	want := []string{"server 1: version 1 -> 2 [store the greeting hour as a number]"}
+	if !reflect.DeepEqual(output, want) {
+		t.Errorf("expected dry run output %q, got %q", want, output)
+	}
+	if _, err = store.GetServerContent(ctx, "1"); err == nil {
+		t.Errorf("expected the dry run to leave the legacy document unchanged")
+	}
+
+	if results, err = store.Migrate(ctx, false); err != nil || len(results) != 1 {
+		t.Fatalf("expected 1 server to be migrated, got %v: %v", results, err)
+	}
+	serverContent, err := store.GetServerContent(ctx, "1")
+	if err != nil {
+		t.Fatalf("getting migrated server: %s", err)
+	}
+	if serverContent.Time != 9 || serverContent.SchemaVersion != CurrentSchemaVersion || serverContent.Channel != "2" {
+		t.Errorf("unexpected migrated server %+v", serverContent)
+	}
+	if results, err = store.Migrate(ctx, false); err != nil || len(results) != 0 {
+		t.Errorf("expected nothing left to migrate, got %v: %v", results, err)
+	}
+}
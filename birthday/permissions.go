@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/joshjennings98/discord-bot/platform"
+	"github.com/joshjennings98/discord-bot/utils"
+)
+
+type PermissionLevel int
+
+const (
+	PermissionEveryone PermissionLevel = iota
+	PermissionAdmin
+)
+
+// IsAdmin reports whether the author of the command can run administrative actions, either because
+// they can manage the server or because they have the bot admin role configured for it.
+func (d *DiscordBot) IsAdmin(command *Command) bool {
+	if platform.IsModerator(d.directory, command.Channel, command.Author) {
+		return true
+	}
+	serverContent, err := d.store.GetServerContent(command.Context(), command.Database)
+	if err != nil || serverContent.AdminRole == "" {
+		return false
+	}
+	return platform.HasRole(d.directory, command.Server, command.Author, serverContent.AdminRole)
+}
+
+// HasPermission checks the author of the command is allowed to run it and tells them if they aren't.
+func (d *DiscordBot) HasPermission(command *Command, definition *CommandDefinition) bool {
+	if definition.Permission != PermissionAdmin || d.IsAdmin(command) {
+		return true
+	}
+	message := fmt.Sprintf("You don't have permission to run '%s'. It requires the Manage Server permission or the bot admin role.", command.Action)
+	d.Reply(command, message, nil)
+	return false
+}
+
+func (d *DiscordBot) AdminRole(command *Command) {
+	var role, message string
+	if command.Arg("role") == "" {
+		message = "Removed the bot admin role."
+	} else {
+		role = utils.GetRoleIDFromMention(command.Arg("role"))
+		if !platform.IsRole(d.directory, command.Server, role) {
+			message = fmt.Sprintf("Invalid role '%s'.", command.Arg("role"))
+			d.Reply(command, message, nil)
+			return
+		}
+		message = fmt.Sprintf("Members with <@&%s> can now run admin commands.", role)
+	}
+	err := d.store.SetServerSetting(command.Context(), command.Database, SettingAdminRole, role)
+	if err != nil {
+		message = fmt.Sprintf("Error updating server settings: %s.", err.Error())
+	}
+	d.Reply(command, message, err)
+}
@@ -0,0 +1,341 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshjennings98/discord-bot/utils"
+)
+
+const maxPrefixLength = 10
+
+type ArgumentType int
+
+const (
+	ArgumentString ArgumentType = iota
+	ArgumentText                // consumes the rest of the command, so must be the last argument
+	ArgumentUser
+	ArgumentRole
+	ArgumentDate
+	ArgumentTimezone
+	ArgumentHour
+	ArgumentChoice
+)
+
+type Argument struct {
+	Name        string
+	Type        ArgumentType
+	Description string
+	Required    bool
+	// Placeholder is shown in usage instead of the name if set
+	Placeholder string
+	// Choices are the allowed values of an ArgumentChoice
+	Choices []string
+	// Validate replaces the validation for the type if set
+	Validate func(value string) error
+}
+
+type CommandDefinition struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Arguments   []Argument
+	Permission  PermissionLevel
+	Execute     func(*DiscordBot, *Command)
+}
+
+// Commands is the registry of every command the bot understands, in the order they are shown in help.
+// Parsing, validation, help and slash commands are all generated from it.
+var Commands []*CommandDefinition
+
+// Set in init since help refers back to Commands
+func init() {
+	Commands = []*CommandDefinition{
+		{
+			Name:        "add",
+			Description: "set a users birthday in the database",
+			Arguments: []Argument{
+				{Name: "user", Type: ArgumentUser, Description: "user to set the birthday of", Required: true},
+				{Name: "date", Type: ArgumentDate, Description: "birthday", Required: true},
+			},
+			Execute: (*DiscordBot).AddBirthday,
+		},
+		{
+			Name:        "remove",
+			Aliases:     []string{"rm", "delete"},
+			Description: "remove a users birthday from the database",
+			Arguments: []Argument{
+				// Not an ArgumentUser since the user may have already left the server
+				{Name: "user", Type: ArgumentString, Description: "user to remove the birthday of", Required: true},
+			},
+			Execute: (*DiscordBot).RemoveBirthday,
+		},
+		{
+			Name:        "me",
+			Description: "set, see or forget your own birthday",
+			Arguments: []Argument{
+				{Name: "date", Type: ArgumentString, Description: "birthday to set, or 'forget' to remove it", Placeholder: "dd/mm/forget", Validate: func(value string) error {
+					if value == "forget" {
+						return nil
+					}
+					return validateArgument(ArgumentDate, value)
+				}},
+			},
+			Execute: (*DiscordBot).MyBirthday,
+		},
+		{
+			Name:        "next",
+			Description: "see who is having their birthday next",
+			Execute:     (*DiscordBot).NextBirthday,
+		},
+		{
+			Name:        "today",
+			Description: "check who is having their birthday today",
+			Execute:     (*DiscordBot).TodaysBirthdays,
+		},
+		{
+			Name:        "when",
+			Description: "see a specific users birthday",
+			Arguments: []Argument{
+				{Name: "user", Type: ArgumentUser, Description: "user to look up", Required: true},
+			},
+			Execute: (*DiscordBot).WhenBirthday,
+		},
+		{
+			Name:        "list",
+			Description: "see everyones birthday, starting with the next one",
+			Execute:     (*DiscordBot).ListBirthdays,
+		},
+		{
+			Name:        "setup",
+			Description: "run the setup, birthday messages are sent to the channel this is run from",
+			Arguments: []Argument{
+				{Name: "timezone", Type: ArgumentTimezone, Description: "timezone of the server", Required: true},
+				{Name: "hour", Type: ArgumentHour, Description: "hour to send birthday messages", Required: true},
+				{Name: "role", Type: ArgumentRole, Description: "role to give members for a day on their birthday"},
+			},
+			Permission: PermissionAdmin,
+			Execute:    (*DiscordBot).StartDiscordBot,
+		},
+		{
+			Name:        "restrict",
+			Description: "only allow moderators to set or remove other users birthdays",
+			Arguments: []Argument{
+				{Name: "enabled", Type: ArgumentChoice, Description: "whether the restriction is enabled", Required: true, Choices: []string{"on", "off"}},
+			},
+			Permission: PermissionAdmin,
+			Execute:    (*DiscordBot).RestrictAdd,
+		},
+		{
+			Name:        "reminders",
+			Description: "send reminders a number of days before each birthday, e.g. `7,1`, or `off`",
+			Arguments: []Argument{
+				{Name: "days", Type: ArgumentText, Description: "days before each birthday to send a reminder, or 'off'", Required: true, Placeholder: "days/off", Validate: func(value string) error {
+					_, err := ParseReminderDays(value)
+					return err
+				}},
+			},
+			Permission: PermissionAdmin,
+			Execute:    (*DiscordBot).SetReminders,
+		},
+		{
+			Name:        "prefix",
+			Description: "change the command prefix, mentioning the bot always works as a prefix too",
+			Arguments: []Argument{
+				{Name: "prefix", Type: ArgumentString, Description: "new command prefix", Required: true, Validate: validatePrefix},
+			},
+			Permission: PermissionAdmin,
+			Execute:    (*DiscordBot).SetPrefix,
+		},
+		{
+			Name:        "adminrole",
+			Description: "set a role that can run admin commands",
+			Arguments: []Argument{
+				{Name: "role", Type: ArgumentRole, Description: "role to allow, leave empty to remove the bot admin role"},
+			},
+			Permission: PermissionAdmin,
+			Execute:    (*DiscordBot).AdminRole,
+		},
+		{
+			Name:        "birthdayrole",
+			Description: "set a role to give members for a day on their birthday",
+			Arguments: []Argument{
+				{Name: "role", Type: ArgumentRole, Description: "role to give, leave empty to stop giving a birthday role"},
+			},
+			Permission: PermissionAdmin,
+			Execute:    (*DiscordBot).BirthdayRole,
+		},
+		{
+			Name:        "greeting",
+			Description: "add, list, remove or preview the birthday message templates, one is picked at random",
+			Arguments: []Argument{
+				{Name: "action", Type: ArgumentChoice, Description: "what to do with the templates", Required: true, Choices: []string{"add", "list", "remove", "preview"}},
+				{Name: "template", Type: ArgumentText, Description: "template to add or preview, or the number of one to remove or preview", Placeholder: "template/number"},
+			},
+			Permission: PermissionAdmin,
+			Execute:    (*DiscordBot).Greeting,
+		},
+		{
+			Name:        "help",
+			Description: "see this help message, or the help for a command",
+			Arguments: []Argument{
+				{Name: "command", Type: ArgumentString, Description: "command to see the help for"},
+			},
+			Execute: (*DiscordBot).Help,
+		},
+	}
+}
+
+// LookupCommand finds a command by its name or one of its aliases.
+func LookupCommand(name string) (definition *CommandDefinition, ok bool) {
+	name = strings.ToLower(name)
+	for _, definition := range Commands {
+		if definition.Name == name || utils.Contains(definition.Aliases, name) {
+			return definition, true
+		}
+	}
+	return nil, false
+}
+
+func (a Argument) placeholder() string {
+	if a.Placeholder != "" {
+		return a.Placeholder
+	}
+	switch a.Type {
+	case ArgumentDate:
+		return "dd/mm"
+	case ArgumentHour:
+		return "hour 0..23"
+	case ArgumentChoice:
+		return strings.Join(a.Choices, "/")
+	default:
+		return a.Name
+	}
+}
+
+// Usage returns how to call the command with the given prefix, e.g. "!bd add <user> <dd/mm>".
+func (c *CommandDefinition) Usage(prefix string) string {
+	usage := []string{prefix, c.Name}
+	for _, argument := range c.Arguments {
+		if argument.Required {
+			usage = append(usage, fmt.Sprintf("<%s>", argument.placeholder()))
+		} else {
+			usage = append(usage, fmt.Sprintf("[%s]", argument.placeholder()))
+		}
+	}
+	return strings.Join(usage, " ")
+}
+
+// Help returns the detailed help for the command.
+func (c *CommandDefinition) Help(prefix string) string {
+	var help strings.Builder
+	fmt.Fprintf(&help, "`%s` - %s\n", c.Usage(prefix), c.Description)
+	if len(c.Aliases) > 0 {
+		fmt.Fprintf(&help, "**Aliases:** %s\n", strings.Join(c.Aliases, ", "))
+	}
+	for _, argument := range c.Arguments {
+		optional := ""
+		if !argument.Required {
+			optional = " (optional)"
+		}
+		fmt.Fprintf(&help, "`%s`%s - %s\n", argument.placeholder(), optional, argument.Description)
+	}
+	if c.Permission == PermissionAdmin {
+		help.WriteString("Requires the Manage Server permission or the bot admin role.\n")
+	}
+	return strings.TrimSuffix(help.String(), "\n")
+}
+
+// HelpMessage returns the usage of every command.
+func HelpMessage(prefix string) string {
+	var help strings.Builder
+	help.WriteString("**BirthdayBot Usage:**")
+	for _, definition := range Commands {
+		fmt.Fprintf(&help, "\n`%s` - %s", definition.Usage(prefix), definition.Description)
+	}
+	return help.String()
+}
+
+// BindArguments assigns positional arguments to the arguments of the command.
+func (c *CommandDefinition) BindArguments(prefix string, values []string) (args map[string]string, err error) {
+	args = map[string]string{}
+	for i, argument := range c.Arguments {
+		if i >= len(values) {
+			break
+		}
+		if argument.Type == ArgumentText {
+			args[argument.Name] = strings.Join(values[i:], " ")
+			return
+		}
+		args[argument.Name] = values[i]
+	}
+	if len(values) > len(c.Arguments) {
+		err = fmt.Errorf("too many arguments, usage is `%s`", c.Usage(prefix))
+	}
+	return
+}
+
+// ValidateArguments checks every required argument is present and every argument is valid.
+func (c *CommandDefinition) ValidateArguments(prefix string, args map[string]string) error {
+	for _, argument := range c.Arguments {
+		value, ok := args[argument.Name]
+		if !ok || value == "" {
+			if argument.Required {
+				return fmt.Errorf("missing argument `<%s>`, usage is `%s`", argument.placeholder(), c.Usage(prefix))
+			}
+			continue
+		}
+		var err error
+		if argument.Validate != nil {
+			err = argument.Validate(value)
+		} else if argument.Type == ArgumentChoice {
+			if !utils.Contains(argument.Choices, value) {
+				err = fmt.Errorf("must be one of %s", strings.Join(argument.Choices, ", "))
+			}
+		} else {
+			err = validateArgument(argument.Type, value)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s': %s", argument.Name, value, err.Error())
+		}
+	}
+	return nil
+}
+
+func validatePrefix(value string) error {
+	if len(value) > maxPrefixLength {
+		return fmt.Errorf("must be at most %d characters", maxPrefixLength)
+	}
+	if strings.HasPrefix(value, "<") {
+		return fmt.Errorf("can't be a mention")
+	}
+	return nil
+}
+
+func validateArgument(argumentType ArgumentType, value string) error {
+	switch argumentType {
+	case ArgumentUser:
+		if _, err := strconv.ParseUint(utils.GetIDFromMention(value), 10, 64); err != nil {
+			return fmt.Errorf("must be a user mention or id")
+		}
+	case ArgumentRole:
+		if _, err := strconv.ParseUint(utils.GetRoleIDFromMention(value), 10, 64); err != nil {
+			return fmt.Errorf("must be a role mention or id")
+		}
+	case ArgumentDate:
+		if !utils.IsValidDate(value) {
+			return fmt.Errorf("must be a date in the form dd/mm")
+		}
+	case ArgumentTimezone:
+		if _, err := time.LoadLocation(value); err != nil {
+			return fmt.Errorf("must be a timezone such as Europe/London")
+		}
+	case ArgumentHour:
+		if hour, err := strconv.Atoi(value); err != nil || hour < 0 || hour > 23 {
+			return fmt.Errorf("must be within 0 and 23 (inclusive)")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshjennings98/discord-bot/platform"
+	"github.com/joshjennings98/discord-bot/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxReminders    = 5
+	maxReminderDays = 60
+)
+
+// ParseReminderDays parses a list of days before a birthday to send reminders, separated by commas or
+// spaces. "off" clears the reminders.
+func ParseReminderDays(value string) (days []int, err error) {
+	if value == "off" {
+		return []int{}, nil
+	}
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		day, err := strconv.Atoi(field)
+		if err != nil || day < 1 || day > maxReminderDays {
+			return nil, fmt.Errorf("'%s' must be a number of days between 1 and %d", field, maxReminderDays)
+		}
+		if !utils.Contains(days, day) {
+			days = append(days, day)
+		}
+	}
+	if len(days) == 0 {
+		return nil, fmt.Errorf("must be a list of days or 'off'")
+	}
+	if len(days) > maxReminders {
+		return nil, fmt.Errorf("at most %d reminders can be set", maxReminders)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(days)))
+	return days, nil
+}
+
+func (d *DiscordBot) SetReminders(command *Command) {
+	days, _ := ParseReminderDays(command.Arg("days")) // We know at this point that the days are valid
+	err := d.store.SetServerSetting(command.Context(), command.Database, SettingReminders, days)
+	if err != nil {
+		message := fmt.Sprintf("Error updating server settings: %s.", err.Error())
+		d.Reply(command, message, err)
+		return
+	}
+	message := "Birthday reminders turned off."
+	if len(days) > 0 {
+		var formatted []string
+		for _, day := range days {
+			formatted = append(formatted, strconv.Itoa(day))
+		}
+		message = fmt.Sprintf("Reminders will be sent %s days before each birthday.", strings.Join(formatted, ", "))
+	}
+	d.Reply(command, message, nil)
+}
+
+// SendBirthdayReminders posts a reminder to the servers channel for each birthday that had a reminder
+// due on any day from the day of from to the day of to, so reminders missed while the bot was offline
+// can be sent together. Each birthday gets at most one reminder, counting the days from today. Sent
+// reminders are recorded so that they aren't sent again when the same days are caught up on after a
+// restart.
+func (d *DiscordBot) SendBirthdayReminders(database string, from, to time.Time) {
+	serverContent, err := d.store.GetServerContent(d.Context(), database)
+	if err != nil {
+		log.Errorf("Failed to get the server settings from the database.")
+		return
+	}
+	if len(serverContent.Reminders) == 0 {
+		return
+	}
+	loc, err := time.LoadLocation(serverContent.Timezone)
+	if err != nil {
+		log.Errorf("Invalid location '%s'", serverContent.Timezone)
+		return
+	}
+	now, first, last := d.now().In(loc), from.In(loc), to.In(loc)
+	birthdays, err := d.store.GetBirthdaysFromDatabase(d.Context(), database)
+	if err != nil {
+		log.Errorf("Failed to get upcoming birthdays from the database.")
+		return
+	}
+	sort.Sort(birthdays)
+	for _, b := range birthdays {
+		next := nextOccurrence(b.Date, now)
+		remaining := daysBetween(now, next)
+		if remaining == 0 {
+			continue // the greeting is sent instead
+		}
+		for _, days := range serverContent.Reminders {
+			due := next.AddDate(0, 0, -days)
+			if daysBetween(first, due) < 0 || daysBetween(due, last) < 0 {
+				continue
+			}
+			d.sendReminder(serverContent, database, b.ID, due, remaining)
+			break
+		}
+	}
+}
+
+// sendReminder sends the reminder due on day for the user's birthday, unless it already has been. Like
+// greetings, reminders are recorded before they are sent so that they are sent at most once.
+func (d *DiscordBot) sendReminder(serverContent ServerContent, database, id string, day time.Time, remaining int) {
+	recorded, err := d.store.RecordReminder(d.Context(), database, id, day)
+	if err != nil {
+		log.Errorf("Failed to record birthday reminder for %s: %s", id, err.Error())
+		return
+	}
+	if !recorded {
+		log.Info(fmt.Sprintf("Already reminded about %s's birthday on %s", id, day.Format(dayFormat)))
+		return
+	}
+	message := fmt.Sprintf("<@%s>'s birthday is in %d days :calendar:", id, remaining)
+	if remaining == 1 {
+		message = fmt.Sprintf("<@%s>'s birthday is tomorrow :calendar:", id)
+	}
+	if err := platform.LogAndSend(d.messenger, serverContent.Channel, serverContent.Server, message, nil); err != nil {
+		// Forget the reminder so that it is sent if the day is caught up on again
+		if forgetErr := d.store.ForgetReminder(d.Context(), database, id, day); forgetErr != nil {
+			log.Errorf("Failed to forget birthday reminder for %s: %s", id, forgetErr.Error())
+		}
+	}
+}
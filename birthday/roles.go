@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	commonerrors "github.com/joshjennings98/discord-bot/errors"
+	"github.com/joshjennings98/discord-bot/platform"
+	"github.com/joshjennings98/discord-bot/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// grantBirthdayRole gives the user the servers birthday role, if it has one, for a day from when their
+// birthday message was due.
+func (d *DiscordBot) grantBirthdayRole(serverContent ServerContent, database, id string, day time.Time) {
+	if serverContent.BirthdayRole == "" {
+		return
+	}
+	// Record the grant first so the role is still removed if the bot stops right after adding it
+	grant := RoleGrant{ID: id, Role: serverContent.BirthdayRole, Expires: day.AddDate(0, 0, 1)}
+	if err := d.store.AddRoleGrant(d.Context(), database, grant); err != nil {
+		log.Errorf("Failed to record birthday role for %s: %s", id, err.Error())
+		return
+	}
+	log.Info(fmt.Sprintf("Giving %s the birthday role on server %s until %s", id, serverContent.Server, grant.Expires.Format(time.RFC3339)))
+	if err := d.directory.AddMemberRole(serverContent.Server, id, grant.Role); err != nil {
+		log.Errorf("Failed to give %s the birthday role: %s", id, err.Error())
+	}
+}
+
+// RemoveExpiredBirthdayRoles takes away birthday roles that were given out more than a day ago.
+func (d *DiscordBot) RemoveExpiredBirthdayRoles(database string, now time.Time) {
+	serverContent, err := d.store.GetServerContent(d.Context(), database)
+	if err != nil {
+		log.Errorf("Failed to get the server settings from the database.")
+		return
+	}
+	grants, err := d.store.GetRoleGrants(d.Context(), database)
+	if err != nil {
+		log.Errorf("Failed to get birthday roles from the database.")
+		return
+	}
+	for _, grant := range grants {
+		if grant.Expires.After(now) {
+			continue
+		}
+		log.Info(fmt.Sprintf("Removing the birthday role from %s on server %s", grant.ID, serverContent.Server))
+		err := d.directory.RemoveMemberRole(serverContent.Server, grant.ID, grant.Role)
+		// Keep the grant to try again later unless the member or role is gone
+		if err != nil && !errors.Is(err, commonerrors.ErrNotFound) {
+			log.Errorf("Failed to remove the birthday role from %s: %s", grant.ID, err.Error())
+			continue
+		}
+		if err := d.store.RemoveRoleGrant(d.Context(), database, grant.ID); err != nil {
+			log.Errorf("Failed to remove birthday role record for %s: %s", grant.ID, err.Error())
+		}
+	}
+}
+
+// checkBirthdayRole returns a message explaining why the role can't be used as a birthday role, or an
+// empty string if it can.
+func (d *DiscordBot) checkBirthdayRole(command *Command, role string) string {
+	if err := platform.CanAssignRole(d.directory, command.Server, role); err != nil {
+		return fmt.Sprintf("Can't use <@&%s> as the birthday role: %s.", role, err.Error())
+	}
+	return ""
+}
+
+func (d *DiscordBot) BirthdayRole(command *Command) {
+	var role, message string
+	if command.Arg("role") == "" {
+		message = "Removed the birthday role."
+	} else {
+		role = utils.GetRoleIDFromMention(command.Arg("role"))
+		if message = d.checkBirthdayRole(command, role); message != "" {
+			d.Reply(command, message, nil)
+			return
+		}
+		message = fmt.Sprintf("Members will be given <@&%s> for a day on their birthday.", role)
+	}
+	err := d.store.SetServerSetting(command.Context(), command.Database, SettingBirthdayRole, role)
+	if err != nil {
+		message = fmt.Sprintf("Error updating server settings: %s.", err.Error())
+	}
+	d.Reply(command, message, err)
+}
@@ -0,0 +1,413 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/joshjennings98/discord-bot/platform"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxCatchUp is how far back missed birthday messages are sent when the bot starts
+	maxCatchUp = 7 * 24 * time.Hour
+	dayFormat  = "2006-01-02"
+	// roleRetryDelay is how long to wait before trying to remove a birthday role again
+	roleRetryDelay = 5 * time.Minute
+	// retryDelay is how long to wait before retrying server lookups and birthday checks that failed. Checks
+	// that keep failing wait twice as long each time, up to maxRetryDelay, and are given up on after
+	// maxRetries attempts.
+	retryDelay    = time.Minute
+	maxRetryDelay = time.Hour
+	maxRetries    = 10
+)
+
+// Scheduler sends each servers birthday messages and reminders at the configured hour in the servers
+// timezone, and removes birthday roles once they expire. It sleeps until the next server is due and
+// recalculates whenever settings change.
+type Scheduler struct {
+	bot     *DiscordBot
+	changed chan struct{}
+	syncs   chan chan struct{}
+	running int32
+	// failed are each servers checks that failed to send every greeting and are waiting to be retried.
+	// It is only used by Run.
+	failed map[string][]failedCheck
+}
+
+// failedCheck is a birthday check that failed and is waiting to be retried.
+type failedCheck struct {
+	fireTime time.Time
+	attempts int
+	retry    time.Time
+}
+
+func NewScheduler(bot *DiscordBot) *Scheduler {
+	scheduler := &Scheduler{
+		bot:     bot,
+		changed: make(chan struct{}, 1),
+		syncs:   make(chan chan struct{}),
+		failed:  map[string][]failedCheck{},
+	}
+	bot.scheduler = scheduler
+	return scheduler
+}
+
+// Scheduler returns the scheduler created for the bot with NewScheduler, or nil if there isn't one.
+func (d *DiscordBot) Scheduler() *Scheduler {
+	return d.scheduler
+}
+
+// Running reports whether Run is running.
+func (s *Scheduler) Running() bool {
+	return atomic.LoadInt32(&s.running) == 1
+}
+
+// Reschedule makes the scheduler recalculate when each server is next due.
+func (s *Scheduler) Reschedule() {
+	select {
+	case s.changed <- struct{}{}:
+	default: // already pending
+	}
+}
+
+// Sync waits for Run to do everything that is due on the bots clock and to take account of every settings
+// change made before it was called, e.g. so that tests moving a fake clock know when Run has caught up.
+// It gives up when ctx is done.
+func (s *Scheduler) Sync(ctx context.Context) {
+	done := make(chan struct{})
+	select {
+	case s.syncs <- done:
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// NextFireTime returns the first time after now that it is hour o'clock in loc.
+func NextFireTime(now time.Time, loc *time.Location, hour int) time.Time {
+	local := now.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), hour, 0, 0, 0, loc)
+	for !next.After(now) {
+		local = local.AddDate(0, 0, 1)
+		next = time.Date(local.Year(), local.Month(), local.Day(), hour, 0, 0, 0, loc)
+	}
+	return next
+}
+
+// schedule returns the timezone and hour the server wants its birthday messages sent at.
+func schedule(serverContent ServerContent) (loc *time.Location, hour int, err error) {
+	loc, err = time.LoadLocation(serverContent.Timezone)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid location '%s'", serverContent.Timezone)
+	}
+	if serverContent.Time < 0 || serverContent.Time > 23 {
+		return nil, 0, fmt.Errorf("invalid hour %d", serverContent.Time)
+	}
+	return loc, serverContent.Time, nil
+}
+
+// plan is when the scheduler next has work to do.
+type plan struct {
+	fireTimes map[string]time.Time
+	expiries  map[string]time.Time
+	// retry is when to retry failed checks or plan again because a server couldn't be looked up, or zero
+	// if there is nothing to retry
+	retry time.Time
+}
+
+// next returns when the scheduler next needs to wake up, or the zero time if it has nothing to do.
+func (p plan) next() time.Time {
+	return earliest(map[string]time.Time{"fire": earliest(p.fireTimes), "expiry": earliest(p.expiries), "retry": p.retry})
+}
+
+// plan works out when each server is next due and has birthday roles to remove. Servers that can't be
+// looked up, e.g. because the database is briefly unavailable, are tried again after retryDelay rather
+// than being left out until their settings next change. Failed checks are retried when they are due.
+func (s *Scheduler) plan(now time.Time, roleRetry time.Duration) (p plan) {
+	var fireTimesOk, expiriesOk bool
+	p.fireTimes, fireTimesOk = s.nextFireTimes(now)
+	p.expiries, expiriesOk = s.roleExpiries(now, roleRetry)
+	var lookups, checks time.Time
+	if !fireTimesOk || !expiriesOk {
+		lookups = now.Add(retryDelay)
+	}
+	for _, failed := range s.failed {
+		for _, check := range failed {
+			if checks.IsZero() || check.retry.Before(checks) {
+				checks = check.retry
+			}
+		}
+	}
+	p.retry = earliest(map[string]time.Time{"lookups": lookups, "checks": checks})
+	if !p.retry.IsZero() {
+		log.Info(fmt.Sprintf("Retrying failures at %s", p.retry.Format(time.RFC3339)))
+	}
+	return p
+}
+
+// nextFireTimes works out when each configured server is next due. It returns false if any server
+// couldn't be looked up.
+func (s *Scheduler) nextFireTimes(now time.Time) (fireTimes map[string]time.Time, ok bool) {
+	fireTimes = map[string]time.Time{}
+	databases, err := s.bot.store.GetServerKeys(s.bot.Context())
+	if err != nil {
+		log.Errorf("Could not find databases")
+		return fireTimes, false
+	}
+	ok = true
+	for _, db := range databases {
+		serverContent, err := s.bot.store.GetServerContent(s.bot.Context(), db)
+		if err != nil {
+			log.Error(fmt.Sprintf("Could not get server settings from database %s", db))
+			ok = false
+			continue
+		}
+		loc, hour, err := schedule(serverContent)
+		if err != nil {
+			log.Error(fmt.Sprintf("Could not schedule database %s: %s", db, err.Error()))
+			continue
+		}
+		fireTimes[db] = NextFireTime(now, loc, hour)
+	}
+	return fireTimes, ok
+}
+
+// roleExpiries works out when each server next has a birthday role to remove. Roles that have already
+// expired, because removing them failed, are retried after retry. It returns false if any server's
+// birthday roles couldn't be looked up.
+func (s *Scheduler) roleExpiries(now time.Time, retry time.Duration) (expiries map[string]time.Time, ok bool) {
+	expiries = map[string]time.Time{}
+	databases, err := s.bot.store.GetServerKeys(s.bot.Context())
+	if err != nil {
+		log.Errorf("Could not find databases")
+		return expiries, false
+	}
+	ok = true
+	for _, db := range databases {
+		grants, err := s.bot.store.GetRoleGrants(s.bot.Context(), db)
+		if err != nil {
+			log.Error(fmt.Sprintf("Could not get birthday roles from database %s", db))
+			ok = false
+			continue
+		}
+		for _, grant := range grants {
+			due := grant.Expires
+			if !due.After(now) {
+				due = now.Add(retry)
+			}
+			if expiry, ok := expiries[db]; !ok || due.Before(expiry) {
+				expiries[db] = due
+			}
+		}
+	}
+	return expiries, ok
+}
+
+// earliest returns the earliest of the times, ignoring zero times, or the zero time if there are none.
+func earliest(times map[string]time.Time) (next time.Time) {
+	for _, t := range times {
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	return
+}
+
+// markChecked records when the server was last checked for birthdays so missed checks can be caught up.
+func (s *Scheduler) markChecked(database string, now time.Time) {
+	if err := s.bot.store.SetServerSetting(s.bot.Context(), database, SettingLastCheck, now); err != nil {
+		log.Errorf("Failed to record birthday check for database %s: %s", database, err.Error())
+	}
+}
+
+// check sends the servers greetings for the birthday check at fireTime. Greetings for days before
+// today are belated. It returns false if the check failed and should be tried again, checks that failed
+// because the greetings can never be sent, e.g. because the channel was deleted, aren't retried.
+func (s *Scheduler) check(db string, fireTime, now time.Time) (ok bool) {
+	belated := fireTime.Format(dayFormat) != now.In(fireTime.Location()).Format(dayFormat)
+	err := s.bot.WishHappyBirthdays(db, fireTime, belated)
+	if err == nil {
+		return true
+	}
+	if platform.Permanent(err) {
+		log.Errorf("Birthday check for %s in database %s failed and won't be retried: %s", fireTime.Format(dayFormat), db, err.Error())
+		return true
+	}
+	log.Errorf("Birthday check for %s in database %s failed: %s", fireTime.Format(dayFormat), db, err.Error())
+	return false
+}
+
+// checkOrRetry runs the check at fireTime, keeping track of it to be retried after retryDelay if it fails.
+func (s *Scheduler) checkOrRetry(db string, fireTime, now time.Time) {
+	if !s.check(db, fireTime, now) {
+		s.failed[db] = append(s.failed[db], failedCheck{fireTime: fireTime, attempts: 1, retry: now.Add(retryDelay)})
+	}
+}
+
+// checked marks the server as checked unless any of its checks are waiting to be retried, in which
+// case it is marked once they have all been done.
+func (s *Scheduler) checked(db string, now time.Time) {
+	if len(s.failed[db]) == 0 {
+		s.markChecked(db, now)
+	}
+}
+
+// retryFailed runs the failed checks that are due to be retried again. Checks that fail again wait twice
+// as long before the next attempt, and are given up on after maxRetries attempts.
+func (s *Scheduler) retryFailed(now time.Time) {
+	for db, checks := range s.failed {
+		var waiting []failedCheck
+		for _, check := range checks {
+			if check.retry.After(now) {
+				waiting = append(waiting, check)
+				continue
+			}
+			log.Info(fmt.Sprintf("Retrying birthday check for %s in database %s", check.fireTime.Format(dayFormat), db))
+			if s.check(db, check.fireTime, now) {
+				continue
+			}
+			if check.attempts++; check.attempts >= maxRetries {
+				log.Errorf("Giving up on birthday check for %s in database %s after %d attempts", check.fireTime.Format(dayFormat), db, check.attempts)
+				continue
+			}
+			check.retry = now.Add(backoff(check.attempts))
+			waiting = append(waiting, check)
+		}
+		if len(waiting) == 0 {
+			delete(s.failed, db)
+			s.markChecked(db, now)
+			continue
+		}
+		s.failed[db] = waiting
+	}
+}
+
+// backoff is how long to wait before the next attempt at a check that has failed attempts times.
+func backoff(attempts int) time.Duration {
+	delay := retryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// catchUp sends greetings and reminders for birthday checks whose scheduled time passed while the bot
+// was offline, going back at most maxCatchUp. Greetings for earlier days are marked as belated.
+func (s *Scheduler) catchUp(now time.Time) {
+	databases, err := s.bot.store.GetServerKeys(s.bot.Context())
+	if err != nil {
+		log.Errorf("Could not find databases")
+		return
+	}
+	for _, db := range databases {
+		serverContent, err := s.bot.store.GetServerContent(s.bot.Context(), db)
+		if err != nil {
+			log.Error(fmt.Sprintf("Could not get server settings from database %s", db))
+			continue
+		}
+		loc, hour, err := schedule(serverContent)
+		if err != nil {
+			log.Error(fmt.Sprintf("Could not schedule database %s: %s", db, err.Error()))
+			continue
+		}
+		// Nothing to catch up on if the server has never been checked
+		if serverContent.LastCheck.IsZero() {
+			s.markChecked(db, now)
+			continue
+		}
+		start := serverContent.LastCheck
+		if now.Sub(start) > maxCatchUp {
+			start = now.Add(-maxCatchUp)
+		}
+		first := NextFireTime(start, loc, hour)
+		if first.After(now) {
+			s.markChecked(db, now)
+			continue
+		}
+		last := first
+		for fireTime := first; !fireTime.After(now); fireTime = NextFireTime(fireTime, loc, hour) {
+			log.Info(fmt.Sprintf("Catching up on missed birthday check for %s in database %s", fireTime.Format(dayFormat), db))
+			s.checkOrRetry(db, fireTime, now)
+			last = fireTime
+		}
+		s.bot.SendBirthdayReminders(db, first, last)
+		s.checked(db, now)
+	}
+}
+
+// wake returns a channel that receives when the plan is next due, or nil if there is nothing planned so
+// the scheduler just waits for settings to change.
+func (s *Scheduler) wake(p plan) <-chan time.Time {
+	next := p.next()
+	if next.IsZero() {
+		return nil
+	}
+	log.Info(fmt.Sprintf("Next scheduled check at %s", next.Format(time.RFC3339)))
+	return s.bot.after(next.Sub(s.bot.now()))
+}
+
+// runDue does everything in the plan that is due by now.
+func (s *Scheduler) runDue(p plan, now time.Time) {
+	if !p.retry.IsZero() && !p.retry.After(now) {
+		s.retryFailed(now)
+	}
+	for db, fireTime := range p.fireTimes {
+		if !fireTime.After(now) {
+			log.Info(fmt.Sprintf("Checking for birthdays in database %s", db))
+			s.checkOrRetry(db, fireTime, now)
+			s.bot.SendBirthdayReminders(db, fireTime, fireTime)
+			s.checked(db, now)
+		}
+	}
+	for db, expiry := range p.expiries {
+		if !expiry.After(now) {
+			s.bot.RemoveExpiredBirthdayRoles(db, now)
+		}
+	}
+}
+
+// Run catches up on any birthday messages missed while the bot was offline and then sends birthday
+// messages until ctx is cancelled. It returns straight away if the scheduler is already running.
+func (s *Scheduler) Run(ctx context.Context) {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		log.Warn("The scheduler is already running")
+		return
+	}
+	defer atomic.StoreInt32(&s.running, 0)
+
+	s.catchUp(s.bot.now())
+	p := s.plan(s.bot.now(), 0)
+	wake := s.wake(p)
+	for {
+		// Whatever wakes the scheduler up, anything already due is done before planning again so that
+		// it isn't skipped when settings change just as a server is due
+		var synced chan struct{}
+		select {
+		case <-wake:
+		case <-s.changed:
+		case synced = <-s.syncs:
+			select {
+			case <-s.changed:
+			default:
+			}
+		case <-ctx.Done():
+			return
+		}
+		now := s.bot.now()
+		s.runDue(p, now)
+		p = s.plan(now, roleRetryDelay)
+		wake = s.wake(p)
+		if synced != nil {
+			close(synced)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	commonerrors "github.com/joshjennings98/discord-bot/errors"
+	"github.com/joshjennings98/discord-bot/platform"
+)
+
+func TestPlanRetriesFailedLookups(t *testing.T) {
+	ctx := context.Background()
+	store := &flakyStore{MemoryStore: NewMemoryStore()}
+	if err := store.SetupBirthdayDatabase(ctx, "1", "2", "UTC", "1", 9); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)
+	bot := &DiscordBot{}
+	bot.AttachStoreToBot(store)
+	scheduler := NewScheduler(bot)
+
+	store.failing = true
+	p := scheduler.plan(now, roleRetryDelay)
+	if len(p.fireTimes) != 0 {
+		t.Errorf("expected no fire times while the database is failing, got %v", p.fireTimes)
+	}
+	if want := now.Add(retryDelay); !p.next().Equal(want) {
+		t.Errorf("expected to wake up at %s to retry, got %s", want, p.next())
+	}
+
+	store.failing = false
+	p = scheduler.plan(p.next(), roleRetryDelay)
+	if !p.retry.IsZero() {
+		t.Errorf("expected no retry once the database recovers, got %s", p.retry)
+	}
+	if want := time.Date(2026, time.June, 2, 9, 0, 0, 0, time.UTC); !p.next().Equal(want) {
+		t.Errorf("expected the server to be due at %s, got %s", want, p.next())
+	}
+}
+
+// failingConsole is a Console that fails to send messages while failing is set, permanently if
+// forbidden is also set.
+type failingConsole struct {
+	*platform.Console
+	failing   bool
+	forbidden bool
+}
+
+func (f *failingConsole) err(channelID string) error {
+	if f.forbidden {
+		return fmt.Errorf("%w: failed to send to channel %s", commonerrors.ErrForbidden, channelID)
+	}
+	return fmt.Errorf("failed to send to channel %s", channelID)
+}
+
+func (f *failingConsole) SendMessage(channelID, content string) error {
+	if f.failing {
+		return f.err(channelID)
+	}
+	return f.Console.SendMessage(channelID, content)
+}
+
+func (f *failingConsole) SendEmbed(channelID, content string, embed *platform.Embed) error {
+	if f.failing {
+		return f.err(channelID)
+	}
+	return f.Console.SendEmbed(channelID, content, embed)
+}
+
+// newTestScheduler creates a scheduler for a server set up in UTC at 9 o'clock, with birthdays on the
+// 3rd, 5th and 6th of June, reminders the day before and a clock set to now. The servers messages are
+// written to out.
+func newTestScheduler(t *testing.T, now time.Time) (scheduler *Scheduler, store *MemoryStore, console *failingConsole, out *bytes.Buffer) {
+	ctx := context.Background()
+	store = NewMemoryStore()
+	if err := store.SetupBirthdayDatabase(ctx, "1", "2", "UTC", "1", 9); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetServerSetting(ctx, "1", SettingReminders, []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	for id, day := range map[string]int{"1": 3, "2": 5, "3": 6} {
+		if err := store.AddBirthdayToDatabase(ctx, "1", id, time.Date(2001, time.June, day, 0, 0, 0, 0, time.UTC)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out = &bytes.Buffer{}
+	console = &failingConsole{Console: platform.NewConsole(out)}
+	bot := &DiscordBot{}
+	bot.AttachStoreToBot(store)
+	bot.AttachMessengerToBot(console, console)
+	bot.AttachClockToBot(&testClock{now: now})
+	return NewScheduler(bot), store, console, out
+}
+
+func lastCheck(t *testing.T, store Store) time.Time {
+	serverContent, err := store.GetServerContent(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serverContent.LastCheck
+}
+
+func TestNextFireTime(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		loc  *time.Location
+		hour int
+		want time.Time
+	}{
+		{"later today", time.Date(2026, time.June, 1, 8, 0, 0, 0, time.UTC), time.UTC, 9, time.Date(2026, time.June, 1, 9, 0, 0, 0, time.UTC)},
+		{"exactly due", time.Date(2026, time.June, 1, 9, 0, 0, 0, time.UTC), time.UTC, 9, time.Date(2026, time.June, 2, 9, 0, 0, 0, time.UTC)},
+		{"midnight", time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC), time.UTC, 0, time.Date(2026, time.June, 2, 0, 0, 0, 0, time.UTC)},
+		{"just before midnight", time.Date(2026, time.June, 1, 23, 59, 59, 0, time.UTC), time.UTC, 0, time.Date(2026, time.June, 2, 0, 0, 0, 0, time.UTC)},
+		{"end of the year", time.Date(2026, time.December, 31, 10, 0, 0, 0, time.UTC), time.UTC, 9, time.Date(2027, time.January, 1, 9, 0, 0, 0, time.UTC)},
+		{"leap day", time.Date(2028, time.February, 28, 10, 0, 0, 0, time.UTC), time.UTC, 9, time.Date(2028, time.February, 29, 9, 0, 0, 0, time.UTC)},
+		{"other timezone", time.Date(2026, time.June, 1, 9, 30, 0, 0, time.UTC), london, 9, time.Date(2026, time.June, 2, 8, 0, 0, 0, time.UTC)},
+		{"clocks going forward", time.Date(2026, time.March, 28, 12, 0, 0, 0, time.UTC), london, 0, time.Date(2026, time.March, 29, 0, 0, 0, 0, time.UTC)},
+		{"day after the clocks go forward", time.Date(2026, time.March, 29, 12, 0, 0, 0, time.UTC), london, 0, time.Date(2026, time.March, 29, 23, 0, 0, 0, time.UTC)},
+		// 1am doesn't exist when the clocks go forward, the check isn't skipped
+		{"hour skipped by the clocks going forward", time.Date(2026, time.March, 28, 12, 0, 0, 0, time.UTC), london, 1, time.Date(2026, time.March, 29, 1, 0, 0, 0, time.UTC)},
+		{"clocks going back", time.Date(2026, time.October, 24, 12, 0, 0, 0, time.UTC), london, 0, time.Date(2026, time.October, 24, 23, 0, 0, 0, time.UTC)},
+		{"day after the clocks go back", time.Date(2026, time.October, 25, 12, 0, 0, 0, time.UTC), london, 0, time.Date(2026, time.October, 26, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := NextFireTime(test.now, test.loc, test.hour); !got.Equal(test.want) {
+				t.Errorf("expected %s, got %s", test.want.UTC(), got.UTC())
+			}
+		})
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	now := time.Date(2026, time.June, 5, 12, 0, 0, 0, time.UTC)
+	scheduler, store, _, out := newTestScheduler(t, now)
+	if err := store.SetServerSetting(context.Background(), "1", SettingLastCheck, time.Date(2026, time.June, 2, 9, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	scheduler.catchUp(now)
+	sent := out.String()
+	for _, expected := range []string{"Happy belated Birthday! :birthday:\n", "<@1>", "Happy Birthday! :birthday:\n", "<@2>", "<@3>'s birthday is tomorrow"} {
+		if !strings.Contains(sent, expected) {
+			t.Errorf("expected '%s' in %q", expected, sent)
+		}
+	}
+	if got := lastCheck(t, store); !got.Equal(now) {
+		t.Errorf("expected the server to be marked as checked at %s, got %s", now, got)
+	}
+
+	// Catching up again has nothing left to send
+	out.Reset()
+	scheduler.catchUp(now)
+	if out.Len() > 0 {
+		t.Errorf("expected no messages, got %q", out.String())
+	}
+
+	// Nor does catching up on the same days after a restart that happened before the check was recorded
+	if err := store.SetServerSetting(context.Background(), "1", SettingLastCheck, time.Date(2026, time.June, 2, 9, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	scheduler.catchUp(now)
+	if out.Len() > 0 {
+		t.Errorf("expected no messages to be sent twice, got %q", out.String())
+	}
+}
+
+func TestCatchUpMarksNewServersChecked(t *testing.T) {
+	now := time.Date(2026, time.June, 5, 12, 0, 0, 0, time.UTC)
+	scheduler, store, _, out := newTestScheduler(t, now)
+
+	scheduler.catchUp(now)
+	if out.Len() > 0 {
+		t.Errorf("expected no messages for a server that has never been checked, got %q", out.String())
+	}
+	if got := lastCheck(t, store); !got.Equal(now) {
+		t.Errorf("expected the server to be marked as checked at %s, got %s", now, got)
+	}
+}
+
+func TestFailedChecksAreRetried(t *testing.T) {
+	now := time.Date(2026, time.June, 5, 12, 0, 0, 0, time.UTC)
+	scheduler, store, console, out := newTestScheduler(t, now)
+	previous := time.Date(2026, time.June, 2, 9, 0, 0, 0, time.UTC)
+	if err := store.SetServerSetting(context.Background(), "1", SettingLastCheck, previous); err != nil {
+		t.Fatal(err)
+	}
+
+	console.failing = true
+	scheduler.catchUp(now)
+	if got := lastCheck(t, store); !got.Equal(previous) {
+		t.Errorf("expected the server not to be marked as checked while greetings fail, got %s", got)
+	}
+	if p := scheduler.plan(now, roleRetryDelay); !p.retry.Equal(now.Add(retryDelay)) {
+		t.Errorf("expected a retry at %s, got %s", now.Add(retryDelay), p.retry)
+	}
+
+	console.failing = false
+	retry := now.Add(retryDelay)
+	scheduler.retryFailed(retry)
+	sent := out.String()
+	for _, expected := range []string{"Happy belated Birthday! :birthday:\n", "<@1>", "Happy Birthday! :birthday:\n", "<@2>"} {
+		if !strings.Contains(sent, expected) {
+			t.Errorf("expected '%s' in %q", expected, sent)
+		}
+	}
+	if got := lastCheck(t, store); !got.Equal(retry) {
+		t.Errorf("expected the server to be marked as checked at %s, got %s", retry, got)
+	}
+	if len(scheduler.failed) > 0 {
+		t.Errorf("expected no failed checks left, got %v", scheduler.failed)
+	}
+}
+
+func TestServersAreMarkedCheckedOnceEveryRetrySucceeds(t *testing.T) {
+	now := time.Date(2026, time.June, 5, 12, 0, 0, 0, time.UTC)
+	scheduler, store, console, _ := newTestScheduler(t, now)
+	previous := time.Date(2026, time.June, 2, 9, 0, 0, 0, time.UTC)
+	if err := store.SetServerSetting(context.Background(), "1", SettingLastCheck, previous); err != nil {
+		t.Fatal(err)
+	}
+
+	console.failing = true
+	scheduler.catchUp(now)
+	if len(scheduler.failed["1"]) != 2 {
+		t.Fatalf("expected the checks on the 3rd and 5th to fail, got %v", scheduler.failed)
+	}
+
+	// Only the first of the failed checks is due to be retried
+	console.failing = false
+	retry := now.Add(retryDelay)
+	scheduler.failed["1"][1].retry = retry.Add(time.Minute)
+	scheduler.retryFailed(retry)
+	if got := lastCheck(t, store); !got.Equal(previous) {
+		t.Errorf("expected the server not to be marked as checked while a check is waiting to be retried, got %s", got)
+	}
+
+	scheduler.retryFailed(retry.Add(time.Minute))
+	if got := lastCheck(t, store); !got.Equal(retry.Add(time.Minute)) {
+		t.Errorf("expected the server to be marked as checked at %s, got %s", retry.Add(time.Minute), got)
+	}
+}
+
+func TestFailedChecksBackOff(t *testing.T) {
+	now := time.Date(2026, time.June, 5, 12, 0, 0, 0, time.UTC)
+	scheduler, store, console, _ := newTestScheduler(t, now)
+
+	console.failing = true
+	scheduler.checkOrRetry("1", time.Date(2026, time.June, 5, 9, 0, 0, 0, time.UTC), now)
+	var delays []time.Duration
+	for attempts := 1; len(scheduler.failed) > 0; attempts++ {
+		if attempts > maxRetries {
+			t.Fatalf("expected the check to be given up on after %d attempts", maxRetries)
+		}
+		p := scheduler.plan(now, roleRetryDelay)
+		delays = append(delays, p.retry.Sub(now))
+		now = p.retry
+		scheduler.retryFailed(now)
+	}
+	if len(delays) != maxRetries-1 {
+		t.Errorf("expected %d retries, got %d", maxRetries-1, len(delays))
+	}
+	for i, delay := range delays {
+		if i > 0 && delay < delays[i-1] {
+			t.Errorf("expected the delays to increase, got %v", delays)
+		}
+		if delay > maxRetryDelay {
+			t.Errorf("expected the delays to be at most %s, got %v", maxRetryDelay, delays)
+		}
+	}
+	if got := lastCheck(t, store); !got.Equal(now) {
+		t.Errorf("expected the server to be marked as checked once the check was given up on, got %s", got)
+	}
+}
+
+func TestPermanentFailuresAreNotRetried(t *testing.T) {
+	now := time.Date(2026, time.June, 5, 12, 0, 0, 0, time.UTC)
+	scheduler, store, console, _ := newTestScheduler(t, now)
+
+	console.failing, console.forbidden = true, true
+	scheduler.checkOrRetry("1", time.Date(2026, time.June, 5, 9, 0, 0, 0, time.UTC), now)
+	scheduler.checked("1", now)
+	if len(scheduler.failed) > 0 {
+		t.Errorf("expected no checks to retry, got %v", scheduler.failed)
+	}
+	if p := scheduler.plan(now, roleRetryDelay); !p.retry.IsZero() {
+		t.Errorf("expected no retry, got %s", p.retry)
+	}
+	if got := lastCheck(t, store); !got.Equal(now) {
+		t.Errorf("expected the server to be marked as checked at %s, got %s", now, got)
+	}
+}
@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joshjennings98/discord-bot/platform"
+	"github.com/joshjennings98/discord-bot/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxAutocompleteChoices is the most choices Discord accepts in an autocomplete response, or for an option.
+	maxAutocompleteChoices = 25
+	// Discord rejects every slash command when registering them if any name or description is too long
+	maxSlashNameLength        = 32
+	maxSlashDescriptionLength = 100
+)
+
+var minHour, maxHour = 0, 23
+
+// SlashCommand converts the command into a slash command definition.
+func (c *CommandDefinition) SlashCommand() *platform.SlashCommand {
+	slashCommand := &platform.SlashCommand{
+		Name:        c.Name,
+		Description: c.Description,
+	}
+	for _, argument := range c.Arguments {
+		option := &platform.SlashCommandOption{
+			Type:        platform.OptionString,
+			Name:        argument.Name,
+			Description: argument.Description,
+			Required:    argument.Required,
+		}
+		switch argument.Type {
+		case ArgumentUser:
+			option.Type = platform.OptionUser
+		case ArgumentRole:
+			option.Type = platform.OptionRole
+		case ArgumentHour:
+			option.Type = platform.OptionInteger
+			option.MinValue = &minHour
+			option.MaxValue = &maxHour
+		case ArgumentTimezone:
+			option.Autocomplete = true
+		case ArgumentChoice:
+			option.Choices = argument.Choices
+		}
+		slashCommand.Options = append(slashCommand.Options, option)
+	}
+	return slashCommand
+}
+
+// ValidateSlashCommand checks the command can be registered as a slash command.
+func (c *CommandDefinition) ValidateSlashCommand() error {
+	if err := validateSlashText(c.Name, c.Description); err != nil {
+		return fmt.Errorf("command '%s': %w", c.Name, err)
+	}
+	for _, argument := range c.Arguments {
+		if err := validateSlashText(argument.Name, argument.Description); err != nil {
+			return fmt.Errorf("command '%s' argument '%s': %w", c.Name, argument.Name, err)
+		}
+		if len(argument.Choices) > maxAutocompleteChoices {
+			return fmt.Errorf("command '%s' argument '%s': at most %d choices are allowed", c.Name, argument.Name, maxAutocompleteChoices)
+		}
+	}
+	return nil
+}
+
+func validateSlashText(name, description string) error {
+	if name == "" || len(name) > maxSlashNameLength {
+		return fmt.Errorf("name must be between 1 and %d characters", maxSlashNameLength)
+	}
+	if description == "" || len(description) > maxSlashDescriptionLength {
+		return fmt.Errorf("description must be between 1 and %d characters, is %d", maxSlashDescriptionLength, len(description))
+	}
+	return nil
+}
+
+// RegisterSlashCommands registers a slash command for every command in Commands, replacing any
+// previously registered commands.
+func (d *DiscordBot) RegisterSlashCommands() (err error) {
+	var slashCommands []*platform.SlashCommand
+	for _, definition := range Commands {
+		if err = definition.ValidateSlashCommand(); err != nil {
+			return fmt.Errorf("error registering slash commands: %w", err)
+		}
+		slashCommands = append(slashCommands, definition.SlashCommand())
+	}
+	if err = d.messenger.RegisterCommands(slashCommands); err != nil {
+		return fmt.Errorf("error registering slash commands: %w", err)
+	}
+	log.Info(fmt.Sprintf("Registered %d slash commands", len(slashCommands)))
+	return nil
+}
+
+func (d *DiscordBot) ExecuteInteraction(interaction *platform.Interaction) {
+	if !d.begin() {
+		return
+	}
+	defer d.inFlight.Done()
+	switch interaction.Type {
+	case platform.InteractionCommand:
+		command := d.ParseInteraction(interaction)
+		d.execute(&command)
+	case platform.InteractionAutocomplete:
+		d.autocomplete(interaction)
+	}
+}
+
+func (d *DiscordBot) ParseInteraction(interaction *platform.Interaction) (command Command) {
+	command.Interaction = interaction
+	command.ctx = d.Context()
+	command.Action = interaction.Command
+	command.Server = interaction.ServerID
+	command.Channel = interaction.ChannelID
+	command.Database = interaction.ServerID
+	command.Prefix = d.Prefix(command.Database)
+	command.Author = interaction.UserID
+
+	command.Args = map[string]string{}
+	for _, option := range interaction.Options {
+		command.Args[option.Name] = option.Value
+	}
+	return
+}
+
+func (d *DiscordBot) autocomplete(interaction *platform.Interaction) {
+	var choices []string
+	for _, option := range interaction.Options {
+		if !option.Focused || option.Name != "timezone" {
+			continue
+		}
+		typed := strings.ToLower(option.Value)
+		for _, tz := range utils.TimezoneNames() {
+			if strings.Contains(strings.ToLower(tz), typed) {
+				choices = append(choices, tz)
+			}
+			if len(choices) == maxAutocompleteChoices {
+				break
+			}
+		}
+	}
+	if err := d.messenger.Autocomplete(interaction, choices); err != nil {
+		log.Error(err)
+	}
+}
+
+// Reply sends a message in response to a command. Slash commands get an interaction response for the
+// first message and follow up messages after that, prefix commands get a normal channel message.
+func (d *DiscordBot) Reply(command *Command, message string, err error) {
+	if command.Interaction == nil {
+		platform.LogAndSend(d.messenger, command.Channel, command.Server, message, err)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+	}
+	log.Info(fmt.Sprintf("Responding to interaction in channel %s on server %s: '%s'", command.Channel, command.Server, message))
+	d.respond(command, message, nil)
+}
+
+// respond answers an interaction, using follow up messages once it has already been responded to.
+func (d *DiscordBot) respond(command *Command, content string, embeds []*platform.Embed) {
+	var err error
+	if !command.responded {
+		command.responded = true
+		err = d.messenger.RespondToInteraction(command.Interaction, content, embeds)
+	} else {
+		err = d.messenger.FollowupInteraction(command.Interaction, content, embeds)
+	}
+	if err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlashCommandsAreValid(t *testing.T) {
+	for _, definition := range Commands {
+		if err := definition.ValidateSlashCommand(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestValidateSlashCommandLengths(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition CommandDefinition
+	}{
+		{"long name", CommandDefinition{Name: strings.Repeat("a", maxSlashNameLength+1), Description: "description"}},
+		{"long description", CommandDefinition{Name: "name", Description: strings.Repeat("a", maxSlashDescriptionLength+1)}},
+		{"empty description", CommandDefinition{Name: "name"}},
+		{"long argument description", CommandDefinition{Name: "name", Description: "description", Arguments: []Argument{
+			{Name: "argument", Description: strings.Repeat("a", maxSlashDescriptionLength+1)},
+		}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.definition.ValidateSlashCommand(); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
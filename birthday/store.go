@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"context"
+	"time"
+)
+
+// Settings that can be changed with SetServerSetting. Each one is the stored name of a field of ServerContent.
+const (
+	SettingRestrictAdd = "restrictAdd"
+	SettingAdminRole   = "adminRole"
+	SettingPrefix      = "prefix"
+	SettingReminders   = "reminders"
+	SettingLastCheck   = "lastCheck"
+
+	SettingGreetingTemplates = "greetingTemplates"
+	SettingBirthdayRole      = "birthdayRole"
+)
+
+// Store is the storage backend used by the bot for server configuration and birthdays.
+// Each server is identified by its database key (see Command.Database).
+type Store interface {
+	SetupBirthdayDatabase(ctx context.Context, database, defaultChannel, timezone, server string, hour int) error
+	GetServerContent(ctx context.Context, database string) (ServerContent, error)
+	GetServerKeys(ctx context.Context) ([]string, error)
+	SetServerSetting(ctx context.Context, database, setting string, value interface{}) error
+	AddBirthdayToDatabase(ctx context.Context, database, id string, date time.Time) error
+	RemoveBirthdayFromDatabase(ctx context.Context, database, id string) error
+	GetBirthdaysFromDatabase(ctx context.Context, database string) (Birthdays, error)
+	CheckForUsersBirthdayInDatabase(ctx context.Context, database, userID string) (time.Time, error)
+	CheckForBirthdaysInDatabase(ctx context.Context, database string, t time.Time) ([]string, error)
+	// RecordGreeting records that the user has been wished happy birthday for the year. It returns
+	// false if they already had been, so callers can use it to make sure each greeting is sent once.
+	RecordGreeting(ctx context.Context, database, id string, year int) (bool, error)
+	// ForgetGreeting undoes RecordGreeting, e.g. when sending the greeting failed so it can be retried.
+	ForgetGreeting(ctx context.Context, database, id string, year int) error
+	// RecordReminder records that the reminder due on day for the user's birthday has been sent. Like
+	// RecordGreeting it returns false if it already had been.
+	RecordReminder(ctx context.Context, database, id string, day time.Time) (bool, error)
+	// ForgetReminder undoes RecordReminder.
+	ForgetReminder(ctx context.Context, database, id string, day time.Time) error
+	// Role grants track birthday roles that have been given out so they can be taken away again, even
+	// if the bot restarts in between. Each user has at most one grant per server.
+	AddRoleGrant(ctx context.Context, database string, grant RoleGrant) error
+	GetRoleGrants(ctx context.Context, database string) ([]RoleGrant, error)
+	RemoveRoleGrant(ctx context.Context, database, id string) error
+}
+
+// RoleGrant is a birthday role given to a user that needs removing once it expires.
+type RoleGrant struct {
+	ID      string    `bson:"user" json:"user"`
+	Role    string    `bson:"role" json:"role"`
+	Expires time.Time `bson:"expires" json:"expires"`
+}
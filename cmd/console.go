@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	commands "github.com/joshjennings98/discord-bot/birthday"
+	bot "github.com/joshjennings98/discord-bot/discord_bot"
+	"github.com/joshjennings98/discord-bot/platform"
+	"github.com/joshjennings98/discord-bot/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// CLI flags
+	Guild = "guild"
+	User  = "user"
+)
+
+var consoleCmd = &cobra.Command{
+	Use:   "console",
+	Short: "Run commands locally without connecting to Discord.",
+	Long: `Run commands locally without connecting to Discord.
+
+Commands such as '!bd next' are read from stdin, one per line, and run against the chosen store as
+if they were sent in the given guild. Replies are printed to stdout. The user running the commands
+has every permission, and every user mentioned is treated as a member of the guild.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		guild, err := cmd.Flags().GetString(Guild)
+		if err != nil {
+			return err
+		}
+		user, err := cmd.Flags().GetString(User)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := signalContext(context.Background())
+		defer cancel()
+		return RunConsole(ctx, cmd, guild, user)
+	},
+	SilenceUsage: true,
+}
+
+func init() {
+	consoleCmd.Flags().String(Guild, "console", "ID of the guild to run commands in")
+	consoleCmd.Flags().String(User, "1", "ID of the user running the commands")
+	rootCmd.AddCommand(consoleCmd)
+}
+
+func RunConsole(ctx context.Context, cmd *cobra.Command, guild, user string) error {
+	var storeConfig commands.StoreConfiguration
+	if err := utils.LoadFromViper(viperSession, app, &storeConfig, commands.DefaultStoreConfig()); err != nil {
+		return err
+	}
+
+	store, closeStore, err := bot.OpenStore(ctx, storeConfig)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	// Keep the output to the replies
+	log.SetLevel(log.WarnLevel)
+
+	console := platform.NewConsole(cmd.OutOrStdout())
+	discordBot := &commands.DiscordBot{}
+	discordBot.AttachStoreToBot(store)
+	discordBot.AttachMessengerToBot(console, console)
+	discordBot.AttachContextToBot(ctx)
+
+	// Lines are read separately so that CTRL-C stops the console while it is waiting for input
+	lines := make(chan string)
+	var scanErr error
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(cmd.InOrStdin())
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr = scanner.Err()
+	}()
+
+	for i := 1; ; i++ {
+		var line string
+		var ok bool
+		select {
+		case line, ok = <-lines:
+		case <-ctx.Done():
+			return nil
+		}
+		if !ok {
+			return scanErr
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// The bot ignores anything else, so say why nothing happened
+		prefix, mention := discordBot.Prefix(guild), fmt.Sprintf("<@%s>", platform.ConsoleBotID)
+		if first := strings.Fields(line)[0]; first != prefix && first != mention {
+			fmt.Fprintf(cmd.OutOrStdout(), "Commands must start with '%s' or '%s'.\n", prefix, mention)
+			continue
+		}
+		discordBot.HandleMessage(&platform.Message{
+			ID:        fmt.Sprintf("%d", i),
+			ServerID:  guild,
+			ChannelID: guild,
+			AuthorID:  user,
+			Content:   line,
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	commands "github.com/joshjennings98/discord-bot/birthday"
+	bot "github.com/joshjennings98/discord-bot/discord_bot"
+	"github.com/joshjennings98/discord-bot/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// CLI flags
+	DryRun = "dry-run"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate stored server documents to the latest schema version.",
+	Long: fmt.Sprintf(`Migrate stored server documents to the latest schema version (%d).
+
+Use --dry-run to list the migrations that would be applied without writing anything.`, commands.CurrentSchemaVersion),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, err := cmd.Flags().GetBool(DryRun)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := signalContext(context.Background())
+		defer cancel()
+		return RunMigrate(ctx, cmd, dryRun)
+	},
+	SilenceUsage: true,
+}
+
+func init() {
+	migrateCmd.Flags().Bool(DryRun, false, "Show the migrations that would be applied without applying them")
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func RunMigrate(ctx context.Context, cmd *cobra.Command, dryRun bool) error {
+	var storeConfig commands.StoreConfiguration
+	if err := utils.LoadFromViper(viperSession, app, &storeConfig, commands.DefaultStoreConfig()); err != nil {
+		return err
+	}
+
+	store, closeStore, err := bot.OpenUnmigratedStore(ctx, storeConfig)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	migrator, ok := store.(commands.Migrator)
+	if !ok {
+		return fmt.Errorf("store '%s' does not support migrations", storeConfig.Store)
+	}
+	results, err := migrator.Migrate(ctx, dryRun)
+	for _, result := range results {
+		fmt.Fprintln(cmd.OutOrStdout(), result)
+	}
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "All server documents are up to date.")
+	} else if dryRun {
+		fmt.Fprintf(cmd.OutOrStdout(), "%d server documents would be migrated.\n", len(results))
+	}
+	return nil
+}
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	commands "github.com/joshjennings98/discord-bot/birthday"
 	bot "github.com/joshjennings98/discord-bot/discord_bot"
@@ -16,7 +19,9 @@ const (
 	app = "discord_bot"
 	// CLI flags
 	Token      = "token"
+	Store      = "store"
 	MongoDBURI = "mongodb_uri"
+	BoltPath   = "bolt_path"
 )
 
 var (
@@ -32,10 +37,13 @@ Environment variables can be used instead of cli arguments. CLI arguments will t
 
 Environment Variables:
 	DISCORD_BOT_TOKEN 	  	string	Bot token
+	DISCORD_BOT_STORE 	  	string	Storage backend (mongodb, bolt or memory)
 	DISCORD_BOT_MONGODB_URI string 	MongoDB URI Password
+	DISCORD_BOT_BOLT_PATH 	string 	BoltDB file path
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
+		ctx, cancel := signalContext(context.Background())
+		defer cancel()
 		if err := RunCLI(ctx); err != nil {
 			return err
 		}
@@ -61,16 +69,39 @@ func initCLI(ctx context.Context) (err error) {
 
 func init() {
 	rootCmd.Flags().StringP(Token, "t", "", "Bot token")
-	rootCmd.Flags().StringP(MongoDBURI, "p", "", "MongoDB URI Password")
+	rootCmd.PersistentFlags().StringP(Store, "s", "", "Storage backend (mongodb, bolt or memory)")
+	rootCmd.PersistentFlags().StringP(MongoDBURI, "p", "", "MongoDB URI Password")
+	rootCmd.PersistentFlags().StringP(BoltPath, "b", "", "BoltDB file path")
 
 	_ = utils.BindFlagToEnvironmentVariable(viperSession, app, "DISCORD_BOT_TOKEN", rootCmd.Flags().Lookup(Token))
-	_ = utils.BindFlagToEnvironmentVariable(viperSession, app, "DISCORD_BOT_MONGODB_URI", rootCmd.Flags().Lookup(MongoDBURI))
+	_ = utils.BindFlagToEnvironmentVariable(viperSession, app, "DISCORD_BOT_STORE", rootCmd.PersistentFlags().Lookup(Store))
+	_ = utils.BindFlagToEnvironmentVariable(viperSession, app, "DISCORD_BOT_MONGODB_URI", rootCmd.PersistentFlags().Lookup(MongoDBURI))
+	_ = utils.BindFlagToEnvironmentVariable(viperSession, app, "DISCORD_BOT_BOLT_PATH", rootCmd.PersistentFlags().Lookup(BoltPath))
+}
+
+// signalContext returns a context that is cancelled on CTRL-C or other term signal so that every
+// subcommand can shut down cleanly. The returned function must be called to stop listening for signals.
+func signalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sc:
+			log.Info(fmt.Sprintf("Received %s", sig))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sc)
+		cancel()
+	}
 }
 
 func RunCLI(ctx context.Context) error {
 	if err := initCLI(ctx); err != nil {
 		return err
 	}
-
-	return bot.StartBot()
+	return bot.StartBot(ctx)
 }
@@ -2,17 +2,14 @@ package discord_bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"os/signal"
-	"strconv"
-	"strings"
-	"syscall"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
 	commands "github.com/joshjennings98/discord-bot/birthday"
-	"github.com/joshjennings98/discord-bot/utils"
+	"github.com/joshjennings98/discord-bot/platform"
 	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -26,38 +23,113 @@ var (
 )
 
 const (
-	prefixCmd = "!bd"
 	defaultDB = ""
+
+	connectTimeout    = 10 * time.Second
+	disconnectTimeout = 5 * time.Second
+	// drainTimeout is how long commands still running at shutdown get to finish
+	drainTimeout = 10 * time.Second
+	// cancelTimeout is how long commands still running after drainTimeout get to stop once they have
+	// been cancelled, before the store they use is closed
+	cancelTimeout = 5 * time.Second
+	// disallowedIntents is the gateway close code sent when the bot asks for a privileged intent it
+	// hasn't been granted
+	disallowedIntents = 4014
 )
 
-func ConnectToMongoDB(ctx context.Context) (c *mongo.Client) {
-	client, err := mongo.NewClient(options.Client().ApplyURI(BotConfig.MongoDBURI))
+func ConnectToMongoDB(ctx context.Context, uri string) (c *mongo.Client, err error) {
+	client, err := mongo.NewClient(options.Client().ApplyURI(uri))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	err = client.Connect(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	err = client.Ping(ctx, readpref.Primary())
 	if err != nil {
-		log.Fatal(err)
+		disconnectFromMongoDB(client)
+		return nil, err
 	}
 	databases, err := client.ListDatabaseNames(ctx, bson.M{})
 	if err != nil {
-		log.Fatal(err)
+		disconnectFromMongoDB(client)
+		return nil, err
 	}
 	fmt.Println("databases", databases)
+	return client, nil
+}
+
+// disconnectFromMongoDB uses its own timeout since the context the client was connected with has
+// usually expired or been cancelled by the time the bot shuts down.
+func disconnectFromMongoDB(client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), disconnectTimeout)
+	defer cancel()
+	if err := client.Disconnect(ctx); err != nil {
+		log.Errorf("Failed to disconnect from MongoDB: %s", err.Error())
+	}
+}
 
-	commands.BirthdaysDatabase = client.Database("BirthdaysDatabase")
-	return client
+// OpenStore opens the storage backend selected in cfg and migrates server documents written by older
+// versions of the bot, which can't be read until they are. The returned function releases it.
+func OpenStore(ctx context.Context, cfg commands.StoreConfiguration) (store commands.Store, closeStore func(), err error) {
+	store, closeStore, err = OpenUnmigratedStore(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if migrator, ok := store.(commands.Migrator); ok {
+		if _, err = migrator.Migrate(ctx, false); err != nil {
+			closeStore()
+			return nil, nil, fmt.Errorf("error migrating stored server documents, check them with 'discord-bot migrate --dry-run': %w", err)
+		}
+	}
+	return store, closeStore, nil
 }
 
-func StartBot() (err error) {
-	// connect to mongodb
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	client := ConnectToMongoDB(ctx)
-	defer client.Disconnect(ctx)
+// OpenUnmigratedStore opens the storage backend selected in cfg without migrating it, so that the
+// migrations can be previewed. The returned function releases it.
+func OpenUnmigratedStore(ctx context.Context, cfg commands.StoreConfiguration) (store commands.Store, closeStore func(), err error) {
+	switch cfg.Store {
+	case commands.StoreBolt:
+		boltStore, err := commands.NewBoltStore(cfg.BoltPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return boltStore, func() { _ = boltStore.Close() }, nil
+	case commands.StoreMemory:
+		return commands.NewMemoryStore(), func() {}, nil
+	default:
+		ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+		defer cancel()
+		client, err := ConnectToMongoDB(ctx, cfg.MongoDBURI)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error connecting to MongoDB: %w", err)
+		}
+		closeStore = func() { disconnectFromMongoDB(client) }
+		mongoStore, err := commands.NewMongoStore(ctx, client.Database("BirthdaysDatabase"))
+		if err != nil {
+			closeStore()
+			return nil, nil, err
+		}
+		return mongoStore, closeStore, nil
+	}
+}
+
+// StartBot runs the bot until ctx is cancelled, then waits for commands and birthday messages that
+// are already being handled before disconnecting.
+func StartBot(ctx context.Context) (err error) {
+	store, closeStore, err := OpenStore(ctx, BotConfig.StoreConfiguration)
+	if err != nil {
+		return fmt.Errorf("error opening store: %w", err)
+	}
+	defer closeStore()
+	DiscordBot.AttachStoreToBot(store)
+
+	// Work gets its own context so it isn't cut off as soon as ctx is cancelled, only once draining
+	// has finished or timed out
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	DiscordBot.AttachContextToBot(workCtx)
 
 	// Create a new Discord session using the provided bot token.
 	dg, err := discordgo.New("Bot " + BotConfig.Token)
@@ -65,80 +137,81 @@ func StartBot() (err error) {
 		return fmt.Errorf("error creating Discord session: %w", err)
 	}
 	defer dg.Close()
-	dg.AddHandler(messageCreate)
+	dg.AddHandler(MessageCreate(&DiscordBot))
+	dg.AddHandler(InteractionCreate(&DiscordBot))
 	dg.AddHandler(onReady)
-	// We only care about receiving message events.
-	dg.Identify.Intents = discordgo.IntentsGuildMessages
+	// Members are needed to give out birthday roles and look up display names. It is a privileged
+	// intent so it has to be enabled for the bot in the developer portal.
+	dg.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsGuildMembers
 
 	// Attach DiscordBot to session
-	DiscordBot.AttachBotToSession(dg)
+	session := platform.NewDiscordSession(dg)
+	DiscordBot.AttachMessengerToBot(session, session)
+	// The scheduler is created before any handlers can run since they use it to reschedule
+	scheduler := commands.NewScheduler(&DiscordBot)
 
 	// Open a websocket connection to Discord and begin listening.
 	err = dg.Open()
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) && closeErr.Code == disallowedIntents {
+		log.Warn("The server members intent isn't enabled for the bot, birthday roles may not work. Enable it in the developer portal.")
+		dg.Identify.Intents = discordgo.IntentsGuildMessages
+		err = dg.Open()
+	}
 	if err != nil {
 		return fmt.Errorf("error opening connection: %w", err)
 	}
 
-	// Wait here until CTRL-C or other term signal is received.
+	// Slash commands are registered alongside the prefix commands rather than replacing them
+	if err = DiscordBot.RegisterSlashCommands(); err != nil {
+		log.Error(err)
+	}
+
+	// Start the scheduler once here rather than on Ready, which is sent again on every reconnect
+	schedulerDone := runScheduler(ctx, scheduler)
+
 	log.Info("Bot is now running.  Press CTRL-C to exit.")
-	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
-	<-sc
+	<-ctx.Done()
+
+	log.Info("Shutting down.")
+	<-schedulerDone
+	if !DiscordBot.Drain(drainTimeout) {
+		log.Warn(fmt.Sprintf("Commands still running after %s, cancelling them", drainTimeout))
+		cancelWork()
+		if !DiscordBot.Drain(cancelTimeout) {
+			log.Warn(fmt.Sprintf("Commands still running %s after being cancelled, closing the store anyway", cancelTimeout))
+		}
+	}
 	return
 }
 
-func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	// Ignore all messages created by the bot itself
-	if m.Author.ID == s.State.User.ID {
-		return
-	}
+// runScheduler runs the scheduler until ctx is cancelled. The returned channel is closed once it has stopped.
+func runScheduler(ctx context.Context, scheduler *commands.Scheduler) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scheduler.Run(ctx)
+	}()
+	return done
+}
 
-	// Check for prefix
-	if strings.HasPrefix(m.Content, prefixCmd) {
-		DiscordBot.ExecuteCommand(m)
+// MessageCreate returns the handler for messages the bot can see, which runs the commands in them.
+func MessageCreate(bot *commands.DiscordBot) func(*discordgo.Session, *discordgo.MessageCreate) {
+	return func(_ *discordgo.Session, m *discordgo.MessageCreate) {
+		bot.HandleMessage(platform.NewMessage(m))
 	}
 }
 
-func onReady(s *discordgo.Session, _ *discordgo.Ready) {
-	ticker := time.NewTicker(1 * time.Hour)
-	quit := make(chan struct{})
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-
-				log.Info("Checking for birthdays")
-
-				databases, err := commands.GetServerKeys()
-				if err != nil {
-					log.Errorf("Could not find databases")
-				}
-				for _, db := range databases {
-					tz, err := commands.GetTimezone(db)
-					if err != nil {
-						log.Error(fmt.Sprintf("Could not get timezone from database %s", db))
-					}
-					loc, err := time.LoadLocation(tz)
-					if err != nil {
-						log.Errorf("Invalid location '%s'", loc)
-					}
-					interval, err := commands.GetTimeInterval(db)
-					if err != nil {
-						log.Errorf("Could not get time interval from database '%s'", db)
-					}
-					i, err := strconv.Atoi(interval)
-					if err != nil {
-						log.Errorf("Invalid interval '%s'", interval)
-					}
-					if utils.InHourInterval(i, time.Now().In(loc)) {
-						commands.WishTodaysHappyBirthdays(s, db)
-					}
-
-				}
-			case <-quit:
-				ticker.Stop()
-				return
-			}
+// InteractionCreate returns the handler for interactions, which runs slash commands and suggests values
+// for their options.
+func InteractionCreate(bot *commands.DiscordBot) func(*discordgo.Session, *discordgo.InteractionCreate) {
+	return func(_ *discordgo.Session, i *discordgo.InteractionCreate) {
+		if interaction, ok := platform.NewInteraction(i); ok {
+			bot.ExecuteInteraction(interaction)
 		}
-	}()
+	}
+}
+
+func onReady(_ *discordgo.Session, r *discordgo.Ready) {
+	log.Info(fmt.Sprintf("Connected to Discord as %s", r.User.String()))
 }
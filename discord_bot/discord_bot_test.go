@@ -0,0 +1,61 @@
+package discord_bot
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	commands "github.com/joshjennings98/discord-bot/birthday"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestOpenStoreMigrates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discord_bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "birthdays.db")
+
+	// A server set up before the hour was stored as a number
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, _ := json.Marshal(map[string]interface{}{"server": "1", "channel": "2", "timezone": "UTC", "time": "9", "schemaVersion": 1})
+	err = db.Update(func(tx *bolt.Tx) error {
+		birthdays, err := tx.CreateBucket([]byte("birthdays"))
+		if err != nil {
+			return err
+		}
+		if _, err = birthdays.CreateBucket([]byte("1")); err != nil {
+			return err
+		}
+		servers, err := tx.CreateBucket([]byte("servers"))
+		if err != nil {
+			return err
+		}
+		return servers.Put([]byte("1"), legacy)
+	})
+	_ = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	store, closeStore, err := OpenStore(ctx, commands.StoreConfiguration{Store: commands.StoreBolt, BoltPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore()
+	serverContent, err := store.GetServerContent(ctx, "1")
+	if err != nil {
+		t.Fatalf("expected the legacy document to be readable: %s", err)
+	}
+	if serverContent.Time != 9 {
+		t.Errorf("unexpected hour %d", serverContent.Time)
+	}
+}
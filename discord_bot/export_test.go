@@ -0,0 +1,7 @@
+package discord_bot
+
+// Exported for the tests in discord_bot_test, which use the harness.
+var (
+	OnReady      = onReady
+	RunScheduler = runScheduler
+)
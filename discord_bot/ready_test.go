@@ -0,0 +1,54 @@
+package discord_bot_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	bot "github.com/joshjennings98/discord-bot/discord_bot"
+	"github.com/joshjennings98/discord-bot/internal/harness"
+)
+
+func TestReadyDoesNotStartSchedulers(t *testing.T) {
+	h := harness.New(nil, time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC))
+	defer h.Close()
+	h.Session.AddMember(harness.Server, "1", "alice")
+	h.Send(harness.Admin, "!bd setup UTC 9")
+	h.Send(harness.Admin, "!bd add <@1> 02/06")
+
+	// Starting the scheduler that is already running doesn't run a second loop
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h.Scheduler.Sync(ctx)
+	select {
+	case <-bot.RunScheduler(ctx, h.Scheduler):
+	case <-time.After(time.Second):
+		t.Fatalf("expected a second run of the scheduler to return straight away")
+	}
+
+	// Ready is sent on the first connection and again every time the bot reconnects
+	ready := &discordgo.Ready{User: &discordgo.User{Username: "BirthdayBot3000", Discriminator: "0001"}}
+	greetings := 0
+	for _, day := range []time.Time{
+		time.Date(2026, time.June, 2, 8, 0, 0, 0, time.UTC),
+		time.Date(2026, time.June, 2, 12, 0, 0, 0, time.UTC),
+		time.Date(2026, time.June, 3, 12, 0, 0, 0, time.UTC),
+	} {
+		for i := 0; i < 5; i++ {
+			bot.OnReady(nil, ready)
+		}
+		for _, message := range h.RunDay(day) {
+			if strings.Contains(message.Text(), "Happy Birthday") {
+				greetings++
+			}
+		}
+	}
+	if greetings != 1 {
+		t.Errorf("expected one greeting, got %d", greetings)
+	}
+	if !h.Scheduler.Running() {
+		t.Errorf("expected the scheduler to still be running")
+	}
+}
@@ -9,4 +9,6 @@ var (
 	ErrCannotParse        = errors.New("cannot parse value")
 	ErrCannotInsertIntoDB = errors.New("cannot insert value into database")
 	ErrCannotUpdateDB     = errors.New("cannot update database")
+	ErrNotFound           = errors.New("not found")
+	ErrForbidden          = errors.New("forbidden")
 )
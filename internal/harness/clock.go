@@ -0,0 +1,77 @@
+package harness
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a utils.Clock that only moves when it is set or advanced. Channels returned by After
+// receive once the clock is moved to or past their deadline.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	deadline time.Time
+	c        chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := waiter{deadline: c.now.Add(d), c: make(chan time.Time, 1)}
+	if !w.deadline.After(c.now) {
+		w.c <- c.now
+		return w.c
+	}
+	c.waiters = append(c.waiters, w)
+	return w.c
+}
+
+// Next returns the earliest deadline of the channels returned by After that haven't received yet, or
+// false if there aren't any.
+func (c *FakeClock) Next() (next time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, w := range c.waiters {
+		if !ok || w.deadline.Before(next) {
+			next, ok = w.deadline, true
+		}
+	}
+	return
+}
+
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+	var waiting []waiter
+	for _, w := range c.waiters {
+		if w.deadline.After(now) {
+			waiting = append(waiting, w)
+			continue
+		}
+		w.c <- now
+	}
+	c.waiters = waiting
+}
+
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
@@ -0,0 +1,243 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	commands "github.com/joshjennings98/discord-bot/birthday"
+	bot "github.com/joshjennings98/discord-bot/discord_bot"
+	"github.com/joshjennings98/discord-bot/platform"
+)
+
+// IDs of the server, channel and users every Harness starts with.
+const (
+	Server       = "100"
+	Channel      = "200"
+	BotID        = "300"
+	Admin        = "400"
+	BotRole      = "500"
+	BirthdayRole = "501"
+)
+
+// Harness runs commands through a DiscordBot backed by a FakeSession, passing them to the same
+// handlers as messages and interactions from Discord, and runs its scheduler against a FakeClock.
+type Harness struct {
+	Bot       *commands.DiscordBot
+	Session   *FakeSession
+	Store     commands.Store
+	Clock     *FakeClock
+	Scheduler *commands.Scheduler
+
+	messageCreate     func(*discordgo.Session, *discordgo.MessageCreate)
+	interactionCreate func(*discordgo.Session, *discordgo.InteractionCreate)
+	messages          int
+	ctx               context.Context
+	stop              context.CancelFunc
+	stopped           chan struct{}
+}
+
+// New creates a harness with a server containing the bot and an admin who can manage the server, with
+// the clock set to now, and starts its scheduler. A memory store is used if store is nil. Close must be
+// called to stop the scheduler.
+func New(store commands.Store, now time.Time) *Harness {
+	if store == nil {
+		store = commands.NewMemoryStore()
+	}
+	session := NewFakeSession(BotID)
+	session.AddRole(Server, &platform.Role{ID: Server, Name: "@everyone"})
+	session.AddRole(Server, &platform.Role{ID: BotRole, Name: "BirthdayBot3000", Position: 2, Permissions: platform.PermissionManageRoles})
+	session.AddRole(Server, &platform.Role{ID: BirthdayRole, Name: "Birthday", Position: 1})
+	session.AddMember(Server, BotID, "BirthdayBot3000", BotRole)
+	session.AddMember(Server, Admin, "admin")
+	session.SetPermissions(Channel, Admin, platform.PermissionManageServer|platform.PermissionSendMessages)
+
+	clock := NewFakeClock(now)
+	discordBot := &commands.DiscordBot{}
+	discordBot.AttachStoreToBot(store)
+	discordBot.AttachMessengerToBot(session, session)
+	discordBot.AttachClockToBot(clock)
+	ctx, stop := context.WithCancel(context.Background())
+	discordBot.AttachContextToBot(ctx)
+	h := &Harness{
+		Bot:       discordBot,
+		Session:   session,
+		Store:     store,
+		Clock:     clock,
+		Scheduler: commands.NewScheduler(discordBot),
+
+		messageCreate:     bot.MessageCreate(discordBot),
+		interactionCreate: bot.InteractionCreate(discordBot),
+		ctx:               ctx,
+		stop:              stop,
+		stopped:           make(chan struct{}),
+	}
+	go func() {
+		defer close(h.stopped)
+		h.Scheduler.Run(ctx)
+	}()
+	return h
+}
+
+// Close stops the scheduler.
+func (h *Harness) Close() {
+	h.stop()
+	<-h.stopped
+}
+
+// Send handles a message from the author in the harness channel and returns the messages the bot sent
+// in response.
+func (h *Harness) Send(author, content string) []SentMessage {
+	h.messages++
+	h.messageCreate(nil, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        strconv.Itoa(h.messages),
+		GuildID:   Server,
+		ChannelID: Channel,
+		Author:    &discordgo.User{ID: author},
+		Content:   content,
+	}})
+	return h.Session.TakeSent()
+}
+
+// Slash runs a slash command from the author in the harness channel and returns the messages the bot
+// sent in response. The input is written as in the Discord client, e.g. `/setup timezone:UTC hour:9`,
+// with users and roles given by ID. Options are typed the way the command registers them.
+func (h *Harness) Slash(author, input string) []SentMessage {
+	fields := strings.Fields(strings.TrimPrefix(input, "/"))
+	if len(fields) == 0 {
+		return nil
+	}
+	data := discordgo.ApplicationCommandInteractionData{Name: fields[0]}
+	types := map[string]platform.OptionType{}
+	if definition, ok := commands.LookupCommand(data.Name); ok {
+		for _, option := range definition.SlashCommand().Options {
+			types[option.Name] = option.Type
+		}
+	}
+	var option *discordgo.ApplicationCommandInteractionDataOption
+	for _, field := range fields[1:] {
+		name := strings.SplitN(field, ":", 2)[0]
+		if _, ok := types[name]; ok && strings.Contains(field, ":") {
+			option = &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionString}
+			option.Value = strings.TrimPrefix(field, name+":")
+			data.Options = append(data.Options, option)
+		} else if option != nil {
+			// Values can contain spaces, e.g. greeting templates
+			option.Value = fmt.Sprintf("%s %s", option.Value, field)
+		}
+	}
+	for _, option := range data.Options {
+		switch types[option.Name] {
+		case platform.OptionInteger:
+			// Discord sends numbers as JSON, so they are decoded as floats
+			value, _ := strconv.ParseFloat(option.Value.(string), 64)
+			option.Type, option.Value = discordgo.ApplicationCommandOptionInteger, value
+		case platform.OptionUser:
+			option.Type = discordgo.ApplicationCommandOptionUser
+		case platform.OptionRole:
+			option.Type = discordgo.ApplicationCommandOptionRole
+		}
+	}
+	h.messages++
+	h.interactionCreate(nil, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        strconv.Itoa(h.messages),
+		Type:      discordgo.InteractionApplicationCommand,
+		Data:      data,
+		GuildID:   Server,
+		ChannelID: Channel,
+		Member:    &discordgo.Member{User: &discordgo.User{ID: author}},
+		Token:     fmt.Sprintf("token%d", h.messages),
+	}})
+	return h.Session.TakeSent()
+}
+
+// RunDay moves the clock forward to day, stopping whenever the scheduler is due so that it sends
+// birthday messages and removes birthday roles as it would in real time, and returns the messages the
+// bot sent. The clock is never moved backwards.
+func (h *Harness) RunDay(day time.Time) []SentMessage {
+	for {
+		h.Scheduler.Sync(h.ctx)
+		next, ok := h.Clock.Next()
+		if !ok || next.After(day) {
+			break
+		}
+		h.Clock.Set(next)
+	}
+	if day.After(h.Clock.Now()) {
+		h.Clock.Set(day)
+		h.Scheduler.Sync(h.ctx)
+	}
+	return h.Session.TakeSent()
+}
+
+// Step is one step of a Scenario. It either sends Input as Author, as a slash command with Slash if it
+// starts with '/', or moves the clock forward to Day with RunDay if it is set. Steps with neither only
+// run Check.
+type Step struct {
+	Author string
+	Input  string
+	Day    time.Time
+	// Expect are strings that must each appear in the messages sent during the step
+	Expect []string
+	// Silent steps must not send any messages
+	Silent bool
+	// Check is an optional extra check run after the step
+	Check func(h *Harness) error
+}
+
+// Scenario is a sequence of steps run against a new Harness, e.g. for table driven tests.
+type Scenario struct {
+	Name string
+	// Now is the time the scenario starts at, the current time if it isn't set
+	Now time.Time
+	// Members are added to the server before the steps are run, keyed by ID with their nickname
+	Members map[string]string
+	Steps   []Step
+}
+
+// Run runs the scenario against a new harness using the given store, or a memory store if it is nil.
+func (s Scenario) Run(store commands.Store) error {
+	now := s.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	h := New(store, now)
+	defer h.Close()
+	for id, nick := range s.Members {
+		h.Session.AddMember(Server, id, nick)
+	}
+	for i, step := range s.Steps {
+		var sent []SentMessage
+		name := step.Input
+		if !step.Day.IsZero() {
+			name = step.Day.Format("2006-01-02")
+			sent = h.RunDay(step.Day)
+		} else if strings.HasPrefix(step.Input, "/") {
+			sent = h.Slash(step.Author, step.Input)
+		} else if step.Input != "" {
+			sent = h.Send(step.Author, step.Input)
+		}
+		var texts []string
+		for _, message := range sent {
+			texts = append(texts, message.Text())
+		}
+		if step.Silent && len(sent) > 0 {
+			return fmt.Errorf("%s: step %d (%s): expected no messages, got %q", s.Name, i+1, name, texts)
+		}
+		all := strings.Join(texts, "\n")
+		for _, expected := range step.Expect {
+			if !strings.Contains(all, expected) {
+				return fmt.Errorf("%s: step %d (%s): expected '%s' in %q", s.Name, i+1, name, expected, texts)
+			}
+		}
+		if step.Check != nil {
+			if err := step.Check(h); err != nil {
+				return fmt.Errorf("%s: step %d (%s): %w", s.Name, i+1, name, err)
+			}
+		}
+	}
+	return nil
+}
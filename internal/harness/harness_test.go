@@ -0,0 +1,66 @@
+package harness
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	commands "github.com/joshjennings98/discord-bot/birthday"
+)
+
+// starts are the times the scenarios are run from, including the end of the year and a leap day.
+var starts = []time.Time{
+	time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC),
+	time.Date(2026, time.December, 31, 23, 30, 0, 0, time.UTC),
+	time.Date(2027, time.December, 28, 12, 0, 0, 0, time.UTC),
+	time.Date(2028, time.February, 29, 12, 0, 0, 0, time.UTC),
+	time.Date(2028, time.February, 28, 23, 0, 0, 0, time.UTC),
+}
+
+// stores are the stores every scenario is run against. The returned function releases the store.
+var stores = map[string]func(t *testing.T) (commands.Store, func()){
+	"memory": func(t *testing.T) (commands.Store, func()) {
+		return commands.NewMemoryStore(), func() {}
+	},
+	"bolt": func(t *testing.T) (commands.Store, func()) {
+		dir, err := ioutil.TempDir("", "harness")
+		if err != nil {
+			t.Fatal(err)
+		}
+		store, err := commands.NewBoltStore(filepath.Join(dir, "birthdays.db"))
+		if err != nil {
+			_ = os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+		return store, func() {
+			_ = store.Close()
+			_ = os.RemoveAll(dir)
+		}
+	},
+}
+
+func TestScenarios(t *testing.T) {
+	for _, start := range starts {
+		t.Run(start.Format(time.RFC3339), func(t *testing.T) {
+			for _, s := range Scenarios(start) {
+				s := s
+				if s.Now.IsZero() {
+					s.Now = start
+				}
+				t.Run(s.Name, func(t *testing.T) {
+					for name, newStore := range stores {
+						t.Run(name, func(t *testing.T) {
+							store, closeStore := newStore(t)
+							defer closeStore()
+							if err := s.Run(store); err != nil {
+								t.Error(err)
+							}
+						})
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,392 @@
+package harness
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/joshjennings98/discord-bot/platform"
+	"github.com/joshjennings98/discord-bot/utils"
+)
+
+// Members of the server in the scenarios.
+const (
+	Alice = "1"
+	Bob   = "2"
+	Carol = "3"
+)
+
+var members = map[string]string{Alice: "alice", Bob: "bob", Carol: "carol"}
+
+// setup is the first step of every scenario since the other commands need the server to be set up.
+var setup = Step{Author: Admin, Input: "!bd setup UTC 9", Expect: []string{"Successfully set up database"}}
+
+// checkOn returns when the birthday check runs on the day of t for servers set up with setup.
+func checkOn(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 9, 0, 0, 0, time.UTC)
+}
+
+func date(t time.Time) string {
+	return t.Format("02/01")
+}
+
+func friendlyDate(t time.Time) string {
+	return fmt.Sprintf("%s %s", t.Month(), utils.AddNumSuffix(t.Day()))
+}
+
+// countdown is how an embed shows the time until t.
+func countdown(t time.Time) string {
+	return fmt.Sprintf("Countdown: <t:%d:R>", t.Unix())
+}
+
+// plainText stops the bot sending embeds in the harness channel, so replies are sent as text.
+func plainText(h *Harness) error {
+	h.Session.SetPermissions(Channel, BotID, platform.PermissionSendMessages)
+	return nil
+}
+
+// failSends makes sending messages fail, or work again.
+func failSends(fail bool) func(h *Harness) error {
+	return func(h *Harness) error {
+		h.Session.FailSends(fail)
+		return nil
+	}
+}
+
+// checkAgain runs the birthday check for day again, as happens when the bot catches up after being
+// offline, which mustn't greet anyone twice.
+func checkAgain(day time.Time) func(h *Harness) error {
+	return func(h *Harness) error {
+		if err := h.Bot.WishHappyBirthdays(Server, day, false); err != nil {
+			return err
+		}
+		if sent := h.Session.TakeSent(); len(sent) > 0 {
+			return fmt.Errorf("expected no messages, got %d", len(sent))
+		}
+		return nil
+	}
+}
+
+func hasRole(id, role string, want bool) func(h *Harness) error {
+	return func(h *Harness) error {
+		member, err := h.Session.Member(Server, id)
+		if err != nil {
+			return err
+		}
+		if utils.Contains(member.Roles, role) != want {
+			return fmt.Errorf("expected <@%s> having role %s to be %t", id, role, want)
+		}
+		return nil
+	}
+}
+
+// Scenarios returns a scenario for every command, starting at now, and scenarios for dates that are easy
+// to get wrong.
+func Scenarios(now time.Time) []Scenario {
+	now = now.UTC()
+	inThreeDays := now.AddDate(0, 0, 3)
+	tomorrow := now.AddDate(0, 0, 1)
+	inTwoDays := now.AddDate(0, 0, 2)
+	inAWeek := now.AddDate(0, 0, 7)
+	inEightDays := now.AddDate(0, 0, 8)
+
+	scenarios := []Scenario{
+		{
+			Name:    "greeting on the day",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: fmt.Sprintf("!bd add <@%s> %s", Alice, date(inThreeDays)), Expect: []string{"Successfully set birthday for <@!1>"}},
+				{Author: Admin, Input: fmt.Sprintf("!bd add <@%s> %s", Bob, date(inAWeek)), Expect: []string{"Successfully set birthday for <@!2>"}},
+				{Author: Admin, Input: fmt.Sprintf("!bd add <@%s> %s", Carol, date(tomorrow)), Expect: []string{"Successfully set birthday for <@!3>"}},
+				{Day: checkOn(tomorrow), Expect: []string{"Happy Birthday <@3>"}},
+				{Day: checkOn(inThreeDays), Expect: []string{"Happy Birthday <@1>"}},
+				// Greetings are only sent once a year
+				{Check: checkAgain(checkOn(inThreeDays))},
+			},
+		},
+		{
+			Name:    "greeting retried after a failed send",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: fmt.Sprintf("!bd add <@%s> %s", Alice, date(inThreeDays))},
+				{Check: failSends(true)},
+				{Day: checkOn(inThreeDays), Silent: true},
+				{Check: failSends(false)},
+				// The scheduler retries the check shortly afterwards
+				{Day: checkOn(inThreeDays).Add(time.Hour), Expect: []string{"Happy Birthday <@1>"}},
+			},
+		},
+		{
+			Name:    "add and when",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: "!bd add <@1> 03/03", Expect: []string{"Successfully set birthday for <@!1> to March 3rd."}},
+				{Author: Admin, Input: "!bd when <@1>", Expect: []string{"alice's birthday", "Date: March 3rd"}},
+				{Author: Admin, Input: "!bd add <@9> 03/03", Expect: []string{"Invalid user '9'."}},
+				{Author: Admin, Input: "!bd add <@1> 31/02", Expect: []string{"invalid date '31/02'"}},
+			},
+		},
+		{
+			Name:    "remove",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: "!bd add <@1> 03/03"},
+				{Author: Admin, Input: "!bd remove <@1>", Expect: []string{"Successfully removed birthday for <@1>."}},
+				{Author: Admin, Input: "!bd rm <@1>", Expect: []string{"<@1>'s birthday not in database."}},
+			},
+		},
+		{
+			Name:    "me",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Bob, Input: "!bd me 05/06", Expect: []string{"Successfully set birthday for <@!2> to June 5th."}},
+				{Author: Bob, Input: "!bd me", Expect: []string{"Date: June 5th"}},
+				{Author: Bob, Input: "!bd me forget", Expect: []string{"Successfully removed birthday for <@2>."}},
+			},
+		},
+		{
+			Name:    "next",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: "!bd next", Expect: []string{"There are no birthdays in the database."}},
+				{Author: Admin, Input: fmt.Sprintf("!bd add <@1> %s", date(inAWeek))},
+				{Author: Admin, Input: fmt.Sprintf("!bd add <@2> %s", date(inThreeDays))},
+				{Author: Admin, Input: "!bd next", Expect: []string{"Next birthday", "<@2>", friendlyDate(inThreeDays)}},
+			},
+		},
+		{
+			Name:    "today",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: "!bd today", Expect: []string{"Nobody has their birthday today"}},
+				{Author: Admin, Input: fmt.Sprintf("!bd add <@1> %s", date(now))},
+				{Author: Admin, Input: fmt.Sprintf("!bd add <@2> %s", date(now))},
+				{Author: Admin, Input: "!bd today", Expect: []string{"<@1> has their birthday today", "<@2> has their birthday today"}},
+			},
+		},
+		{
+			Name:    "setup",
+			Members: members,
+			Steps: []Step{
+				{Author: Alice, Input: "!bd setup UTC 9", Expect: []string{"You don't have permission to run 'setup'"}},
+				{Author: Admin, Input: "!bd setup Nowhere/Special 9", Expect: []string{"invalid timezone"}},
+				{Author: Admin, Input: "!bd setup UTC 24", Expect: []string{"invalid hour"}},
+				{Author: Admin, Input: "!bd setup UTC 9", Expect: []string{"Birthday messages will be sent at 09:00 in timezone 'UTC'."}},
+			},
+		},
+		{
+			Name:    "restrict",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: "!bd restrict on", Expect: []string{"Only moderators can now set or remove other users birthdays."}},
+				{Author: Alice, Input: "!bd add <@2> 01/01", Expect: []string{"Only moderators can set other users birthdays on this server."}},
+				{Author: Alice, Input: "!bd add <@1> 01/01", Expect: []string{"Successfully set birthday for <@!1>"}},
+				{Author: Admin, Input: "!bd add <@2> 02/02", Expect: []string{"Successfully set birthday for <@!2>"}},
+				{Author: Alice, Input: "!bd remove <@2>", Expect: []string{"Only moderators can remove other users birthdays on this server."}},
+				{Author: Bob, Input: "!bd when <@2>", Expect: []string{"February 2nd"}},
+				{Author: Bob, Input: "!bd remove <@2>", Expect: []string{"Successfully removed birthday for <@2>."}},
+				{Author: Admin, Input: "!bd remove <@1>", Expect: []string{"Successfully removed birthday for <@1>."}},
+				{Author: Admin, Input: "!bd restrict off", Expect: []string{"Anyone can now set or remove other users birthdays."}},
+				{Author: Alice, Input: "!bd add <@2> 01/01", Expect: []string{"Successfully set birthday for <@!2>"}},
+			},
+		},
+		{
+			Name:    "reminders",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: "!bd reminders 7,1", Expect: []string{"Reminders will be sent 7, 1 days before each birthday."}},
+				{Author: Admin, Input: fmt.Sprintf("!bd add <@1> %s", date(inEightDays))},
+				{Author: Admin, Input: fmt.Sprintf("!bd add <@2> %s", date(inTwoDays))},
+				{Day: checkOn(tomorrow), Expect: []string{"<@1>'s birthday is in 7 days", "<@2>'s birthday is tomorrow"}},
+				{Author: Admin, Input: "!bd reminders off", Expect: []string{"Birthday reminders turned off."}},
+			},
+		},
+		{
+			Name:    "prefix",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: "!bd prefix ?", Expect: []string{"Successfully set the command prefix to `?`."}},
+				{Author: Admin, Input: "? help", Expect: []string{"`? add <user> <dd/mm>`"}},
+				{Author: Admin, Input: fmt.Sprintf("<@%s> prefix !bd", BotID), Expect: []string{"Successfully set the command prefix to `!bd`."}},
+			},
+		},
+		{
+			Name:    "adminrole",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Alice, Input: "!bd restrict on", Expect: []string{"You don't have permission"}},
+				{Author: Admin, Input: fmt.Sprintf("!bd adminrole <@&%s>", BirthdayRole), Expect: []string{"can now run admin commands"}},
+				{Check: func(h *Harness) error { return h.Session.AddMemberRole(Server, Alice, BirthdayRole) }},
+				{Author: Alice, Input: "!bd restrict on", Expect: []string{"Only moderators can now set or remove other users birthdays."}},
+				{Author: Admin, Input: "!bd adminrole", Expect: []string{"Removed the bot admin role."}},
+			},
+		},
+		{
+			Name:    "birthdayrole",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: fmt.Sprintf("!bd birthdayrole <@&%s>", BotRole), Expect: []string{"bots highest role must be above the role"}},
+				{Author: Admin, Input: fmt.Sprintf("!bd birthdayrole <@&%s>", BirthdayRole), Expect: []string{"Members will be given <@&501> for a day on their birthday."}},
+				{Author: Admin, Input: fmt.Sprintf("!bd add <@1> %s", date(tomorrow))},
+				{Day: checkOn(tomorrow), Expect: []string{"Happy Birthday <@1>"}, Check: hasRole(Alice, BirthdayRole, true)},
+				// The role is removed a day later
+				{Day: checkOn(tomorrow).Add(23 * time.Hour), Silent: true, Check: hasRole(Alice, BirthdayRole, true)},
+				{Day: checkOn(tomorrow).AddDate(0, 0, 1), Check: hasRole(Alice, BirthdayRole, false)},
+			},
+		},
+		{
+			Name:    "greeting templates",
+			Members: members,
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: "!bd greeting list", Expect: []string{"No greeting templates have been added"}},
+				{Author: Admin, Input: "!bd greeting add {{.Nope}}", Expect: []string{"Invalid template"}},
+				{Author: Admin, Input: "!bd greeting add Have a great day {{.Name}}!", Expect: []string{"Added greeting template 1."}},
+				{Author: Admin, Input: "!bd greeting list", Expect: []string{"1. `Have a great day {{.Name}}!`"}},
+				{Author: Admin, Input: "!bd greeting preview 1", Expect: []string{"Have a great day admin!"}},
+				{Author: Admin, Input: fmt.Sprintf("!bd add <@1> %s", date(tomorrow))},
+				{Day: checkOn(tomorrow), Expect: []string{"Have a great day alice!"}},
+				{Author: Admin, Input: "!bd greeting remove 1", Expect: []string{"Removed greeting template 1."}},
+			},
+		},
+		{
+			Name:    "slash commands",
+			Members: members,
+			Steps: []Step{
+				{Author: Alice, Input: "/setup timezone:UTC hour:9", Expect: []string{"You don't have permission to run 'setup'"}},
+				{Author: Admin, Input: "/setup timezone:UTC hour:9", Expect: []string{"Birthday messages will be sent at 09:00 in timezone 'UTC'."}},
+				{Author: Alice, Input: fmt.Sprintf("/add user:%s date:%s", Alice, date(tomorrow)), Expect: []string{"Successfully set birthday for <@!1>"}},
+				{Author: Bob, Input: fmt.Sprintf("/when user:%s", Alice), Expect: []string{friendlyDate(tomorrow)}},
+				{Author: Admin, Input: "/greeting action:add template:Have a great day {{.Name}}!", Expect: []string{"Added greeting template 1."}},
+				{Day: checkOn(tomorrow), Expect: []string{"Have a great day alice!"}},
+			},
+		},
+		{
+			Name:    "help",
+			Members: members,
+			Steps: []Step{
+				{Author: Alice, Input: "!bd help", Expect: []string{"**BirthdayBot Usage:**"}},
+				{Author: Alice, Input: "!bd help rm", Expect: []string{"`!bd remove <user>`", "**Aliases:** rm, delete"}},
+				{Author: Alice, Input: "!bd nope", Expect: []string{"Invalid action 'nope'"}},
+			},
+		},
+	}
+	for i := range scenarios {
+		scenarios[i].Now = now
+	}
+	return append(scenarios, dateScenarios()...)
+}
+
+// dateScenarios run at fixed times around midnight, the end of the year and leap days.
+func dateScenarios() []Scenario {
+	auckland, _ := time.LoadLocation("Pacific/Auckland")
+	return []Scenario{
+		{
+			Name:    "today is the servers day",
+			Members: members,
+			// Already the 15th of June in Auckland
+			Now: time.Date(2026, time.June, 14, 13, 0, 0, 0, time.UTC),
+			Steps: []Step{
+				{Author: Admin, Input: "!bd setup Pacific/Auckland 9", Expect: []string{"Successfully set up database"}},
+				{Author: Admin, Input: "!bd add <@1> 15/06"},
+				{Author: Admin, Input: "!bd add <@2> 14/06"},
+				{Author: Admin, Input: "!bd today", Expect: []string{"<@1> has their birthday today"}},
+				{Author: Admin, Input: "!bd next", Expect: []string{"<@2>", countdown(time.Date(2027, time.June, 14, 0, 0, 0, 0, auckland))}},
+				{Check: plainText},
+				{Author: Admin, Input: "!bd next", Expect: []string{"<@2> in 364 days on June 14th"}},
+			},
+		},
+		{
+			Name:    "midnight checks across daylight saving changes",
+			Members: members,
+			Now:     time.Date(2026, time.March, 28, 12, 0, 0, 0, time.UTC),
+			Steps: []Step{
+				{Author: Admin, Input: "!bd setup Europe/London 0", Expect: []string{"Successfully set up database"}},
+				{Author: Admin, Input: "!bd add <@1> 29/03"},
+				{Author: Admin, Input: "!bd add <@2> 30/03"},
+				{Author: Admin, Input: "!bd add <@3> 25/10"},
+				// Midnight is still in GMT on the day the clocks go forward
+				{Day: time.Date(2026, time.March, 28, 23, 59, 0, 0, time.UTC), Silent: true},
+				{Day: time.Date(2026, time.March, 29, 0, 0, 0, 0, time.UTC), Expect: []string{"Happy Birthday <@1>"}},
+				// and in BST from the next day
+				{Day: time.Date(2026, time.March, 29, 22, 59, 0, 0, time.UTC), Silent: true},
+				{Day: time.Date(2026, time.March, 29, 23, 0, 0, 0, time.UTC), Expect: []string{"Happy Birthday <@2>"}},
+				// The clocks go back after midnight, so the check is still an hour before midnight in UTC
+				{Day: time.Date(2026, time.October, 24, 22, 59, 0, 0, time.UTC), Silent: true},
+				{Day: time.Date(2026, time.October, 24, 23, 0, 0, 0, time.UTC), Expect: []string{"Happy Birthday <@3>"}},
+			},
+		},
+		{
+			Name:    "next wraps around the end of the year",
+			Members: members,
+			Now:     time.Date(2026, time.December, 31, 23, 0, 0, 0, time.UTC),
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: "!bd add <@1> 01/01"},
+				{Author: Admin, Input: "!bd add <@2> 30/12"},
+				{Author: Admin, Input: "!bd next", Expect: []string{"<@1>", countdown(time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC))}},
+				{Check: plainText},
+				{Author: Admin, Input: "!bd next", Expect: []string{"<@1> in 1 days on January 1st"}},
+			},
+		},
+		listScenario(),
+		{
+			Name:    "leap day birthdays",
+			Members: members,
+			Now:     time.Date(2027, time.February, 1, 12, 0, 0, 0, time.UTC),
+			Steps: []Step{
+				setup,
+				{Author: Admin, Input: "!bd add <@1> 29/02", Expect: []string{"to February 29th"}},
+				{Author: Admin, Input: "!bd next", Expect: []string{"Date: February 29th", countdown(time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC))}},
+				// Celebrated on the 1st of March when it isn't a leap year
+				{Day: time.Date(2027, time.February, 28, 9, 0, 0, 0, time.UTC), Silent: true},
+				{Day: time.Date(2027, time.March, 1, 9, 0, 0, 0, time.UTC), Expect: []string{"Happy Birthday <@1>"}},
+				{Day: time.Date(2028, time.February, 29, 9, 0, 0, 0, time.UTC), Expect: []string{"Happy Birthday <@1>"}},
+				{Day: time.Date(2028, time.March, 1, 9, 0, 0, 0, time.UTC), Silent: true},
+			},
+		},
+	}
+}
+
+// listScenario adds a birthday for every day of the year, more than fit in one message, starting from
+// the 17th of October.
+func listScenario() Scenario {
+	start := time.Date(2026, time.October, 17, 12, 0, 0, 0, time.UTC)
+	scenario := Scenario{
+		Name:    "list is split into pages",
+		Members: map[string]string{},
+		Now:     start,
+		Steps:   []Step{setup},
+	}
+	for i := 0; i < 365; i++ {
+		id := fmt.Sprintf("%d", 100000000000000000+i)
+		scenario.Members[id] = fmt.Sprintf("member%d", i)
+		day := start.AddDate(0, 0, i)
+		scenario.Steps = append(scenario.Steps, Step{Author: Admin, Input: fmt.Sprintf("!bd add <@%s> %s", id, date(day))})
+	}
+	scenario.Steps = append(scenario.Steps, Step{
+		Author: Admin,
+		Input:  "!bd list",
+		Expect: []string{"Birthdays (1/", "**October 2026**\n17th - <@100000000000000000>", "**September 2027**", "16th - <@100000000000000364>", "(continued)"},
+		Check: func(h *Harness) error {
+			for _, message := range h.Send(Admin, "!bd list") {
+				if len(message.Text()) > 2000 {
+					return fmt.Errorf("page is %d characters", len(message.Text()))
+				}
+			}
+			return nil
+		},
+	})
+	return scenario
+}
@@ -0,0 +1,264 @@
+package harness
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	commonerrors "github.com/joshjennings98/discord-bot/errors"
+	"github.com/joshjennings98/discord-bot/platform"
+	"github.com/joshjennings98/discord-bot/utils"
+)
+
+// SentMessage is a message the bot sent through a FakeSession.
+type SentMessage struct {
+	ChannelID string
+	Content   string
+	Embed     *platform.Embed
+	// Interaction is set for interaction responses and follow ups
+	Interaction *platform.Interaction
+}
+
+// Text returns the content of the message followed by the text of its embed, if it has one.
+func (m SentMessage) Text() string {
+	parts := []string{}
+	if m.Content != "" {
+		parts = append(parts, m.Content)
+	}
+	if m.Embed != nil {
+		parts = append(parts, m.Embed.Title, m.Embed.Description)
+		for _, field := range m.Embed.Fields {
+			parts = append(parts, fmt.Sprintf("%s: %s", field.Name, field.Value))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// FakeSession is a platform.Messenger and platform.GuildDirectory that keeps servers in memory and
+// records every message sent instead of talking to Discord.
+type FakeSession struct {
+	mu       sync.Mutex
+	botID    string
+	sent     []SentMessage
+	members  map[string]map[string]*platform.Member
+	roles    map[string][]*platform.Role
+	commands []*platform.SlashCommand
+	// failSends makes sending messages to channels fail
+	failSends bool
+	// permissions are per channel and user, anyone else gets defaultPermissions
+	permissions        map[string]map[string]platform.Permissions
+	defaultPermissions platform.Permissions
+}
+
+func NewFakeSession(botID string) *FakeSession {
+	return &FakeSession{
+		botID:              botID,
+		members:            map[string]map[string]*platform.Member{},
+		roles:              map[string][]*platform.Role{},
+		permissions:        map[string]map[string]platform.Permissions{},
+		defaultPermissions: platform.PermissionSendMessages | platform.PermissionEmbedLinks,
+	}
+}
+
+// AddMember adds a user to the server with the given nickname and roles.
+func (f *FakeSession) AddMember(serverID, userID, nick string, roles ...string) *platform.Member {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.members[serverID] == nil {
+		f.members[serverID] = map[string]*platform.Member{}
+	}
+	member := &platform.Member{
+		UserID:   userID,
+		Username: nick,
+		Nick:     nick,
+		Roles:    roles,
+	}
+	f.members[serverID][userID] = member
+	return member
+}
+
+// RemoveMember makes the user leave the server.
+func (f *FakeSession) RemoveMember(serverID, userID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.members[serverID], userID)
+}
+
+// AddRole creates a role on the server.
+func (f *FakeSession) AddRole(serverID string, role *platform.Role) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.roles[serverID] = append(f.roles[serverID], role)
+}
+
+// SetPermissions sets the permissions the user has in the channel.
+func (f *FakeSession) SetPermissions(channelID, userID string, permissions platform.Permissions) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.permissions[channelID] == nil {
+		f.permissions[channelID] = map[string]platform.Permissions{}
+	}
+	f.permissions[channelID][userID] = permissions
+}
+
+// FailSends makes sending messages to channels fail until it is called again with false, as if Discord
+// couldn't be reached.
+func (f *FakeSession) FailSends(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failSends = fail
+}
+
+// Sent returns every message sent so far.
+func (f *FakeSession) Sent() []SentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]SentMessage{}, f.sent...)
+}
+
+// TakeSent returns the messages sent since the last call to TakeSent.
+func (f *FakeSession) TakeSent() []SentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sent := f.sent
+	f.sent = nil
+	return sent
+}
+
+// RegisteredCommands returns the slash commands registered by the bot.
+func (f *FakeSession) RegisteredCommands() []*platform.SlashCommand {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.commands
+}
+
+func (f *FakeSession) record(message SentMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = append(f.sent, message)
+}
+
+// recordSend records a message sent to a channel, unless sends are failing.
+func (f *FakeSession) recordSend(message SentMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failSends {
+		return fmt.Errorf("failed to send message to channel %s", message.ChannelID)
+	}
+	f.sent = append(f.sent, message)
+	return nil
+}
+
+func (f *FakeSession) SendMessage(channelID, content string) error {
+	return f.recordSend(SentMessage{ChannelID: channelID, Content: content})
+}
+
+func (f *FakeSession) SendEmbed(channelID, content string, embed *platform.Embed) error {
+	return f.recordSend(SentMessage{ChannelID: channelID, Content: content, Embed: embed})
+}
+
+func (f *FakeSession) RespondToInteraction(interaction *platform.Interaction, content string, embeds []*platform.Embed) error {
+	f.recordInteraction(interaction, content, embeds)
+	return nil
+}
+
+func (f *FakeSession) FollowupInteraction(interaction *platform.Interaction, content string, embeds []*platform.Embed) error {
+	f.recordInteraction(interaction, content, embeds)
+	return nil
+}
+
+func (f *FakeSession) recordInteraction(interaction *platform.Interaction, content string, embeds []*platform.Embed) {
+	message := SentMessage{ChannelID: interaction.ChannelID, Content: content, Interaction: interaction}
+	if len(embeds) > 0 {
+		message.Embed = embeds[0]
+	}
+	f.record(message)
+}
+
+func (f *FakeSession) Autocomplete(interaction *platform.Interaction, choices []string) error {
+	return nil
+}
+
+func (f *FakeSession) RegisterCommands(commands []*platform.SlashCommand) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.commands = commands
+	return nil
+}
+
+func (f *FakeSession) BotID() string {
+	return f.botID
+}
+
+func (f *FakeSession) Member(serverID, userID string) (*platform.Member, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	member, ok := f.members[serverID][userID]
+	if !ok {
+		return nil, fmt.Errorf("%w: member %s", commonerrors.ErrNotFound, userID)
+	}
+	copied := *member
+	copied.Roles = append([]string{}, member.Roles...)
+	return &copied, nil
+}
+
+func (f *FakeSession) Roles(serverID string) ([]*platform.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]*platform.Role{}, f.roles[serverID]...), nil
+}
+
+func (f *FakeSession) Permissions(userID, channelID string) (platform.Permissions, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if permissions, ok := f.permissions[channelID][userID]; ok {
+		return permissions, nil
+	}
+	return f.defaultPermissions, nil
+}
+
+func (f *FakeSession) AddMemberRole(serverID, userID, roleID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	member, ok := f.members[serverID][userID]
+	if !ok {
+		return fmt.Errorf("%w: member %s", commonerrors.ErrNotFound, userID)
+	}
+	if !utils.Contains(member.Roles, roleID) {
+		member.Roles = append(member.Roles, roleID)
+	}
+	return nil
+}
+
+func (f *FakeSession) RemoveMemberRole(serverID, userID, roleID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	member, ok := f.members[serverID][userID]
+	if !ok {
+		return fmt.Errorf("%w: member %s", commonerrors.ErrNotFound, userID)
+	}
+	var roles []string
+	for _, role := range member.Roles {
+		if role != roleID {
+			roles = append(roles, role)
+		}
+	}
+	member.Roles = roles
+	return nil
+}
@@ -0,0 +1,93 @@
+package platform
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ConsoleBotID is the user ID of the bot when running in a Console.
+const ConsoleBotID = "0"
+
+// Console is a Messenger and GuildDirectory for running commands locally. Messages are written to out,
+// every user is a member of every server with every permission, and servers have no roles.
+type Console struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewConsole(out io.Writer) *Console {
+	return &Console{out: out}
+}
+
+func (c *Console) print(content string, embeds []*Embed) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if content != "" {
+		fmt.Fprintln(c.out, content)
+	}
+	for _, embed := range embeds {
+		if embed.Title != "" {
+			fmt.Fprintln(c.out, embed.Title)
+		}
+		if embed.Description != "" {
+			fmt.Fprintln(c.out, embed.Description)
+		}
+		for _, field := range embed.Fields {
+			fmt.Fprintf(c.out, "%s: %s\n", field.Name, field.Value)
+		}
+	}
+}
+
+func (c *Console) SendMessage(channelID, content string) error {
+	c.print(content, nil)
+	return nil
+}
+
+func (c *Console) SendEmbed(channelID, content string, embed *Embed) error {
+	c.print(content, []*Embed{embed})
+	return nil
+}
+
+func (c *Console) RespondToInteraction(interaction *Interaction, content string, embeds []*Embed) error {
+	c.print(content, embeds)
+	return nil
+}
+
+func (c *Console) FollowupInteraction(interaction *Interaction, content string, embeds []*Embed) error {
+	c.print(content, embeds)
+	return nil
+}
+
+func (c *Console) Autocomplete(interaction *Interaction, choices []string) error {
+	return nil
+}
+
+func (c *Console) RegisterCommands(commands []*SlashCommand) error {
+	return nil
+}
+
+func (c *Console) BotID() string {
+	return ConsoleBotID
+}
+
+func (c *Console) Member(serverID, userID string) (*Member, error) {
+	return &Member{UserID: userID, Username: userID}, nil
+}
+
+func (c *Console) Roles(serverID string) ([]*Role, error) {
+	return nil, nil
+}
+
+func (c *Console) Permissions(userID, channelID string) (Permissions, error) {
+	return PermissionAll, nil
+}
+
+func (c *Console) AddMemberRole(serverID, userID, roleID string) error {
+	return nil
+}
+
+func (c *Console) RemoveMemberRole(serverID, userID, roleID string) error {
+	return nil
+}
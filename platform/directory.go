@@ -0,0 +1,123 @@
+package platform
+
+import (
+	"fmt"
+
+	"github.com/joshjennings98/discord-bot/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+func IsUser(user string, directory GuildDirectory, serverID string) (b bool, id string) {
+	_, err := directory.Member(serverID, user)
+
+	if err != nil {
+		return false, user
+	}
+
+	return true, user
+}
+
+// IsModerator reports whether the user can manage the server the channel belongs to.
+func IsModerator(directory GuildDirectory, channelID, userID string) bool {
+	permissions, err := directory.Permissions(userID, channelID)
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	return permissions.Has(PermissionManageServer)
+}
+
+// CanEmbed reports whether the bot can send embeds in the channel.
+func CanEmbed(directory GuildDirectory, channelID string) bool {
+	permissions, err := directory.Permissions(directory.BotID(), channelID)
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	return permissions.Has(PermissionEmbedLinks)
+}
+
+func IsRole(directory GuildDirectory, serverID, roleID string) bool {
+	roles, err := directory.Roles(serverID)
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	for _, role := range roles {
+		if role.ID == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAssignRole checks the role exists and that the bot can give it to members, which needs the Manage
+// Roles permission and a role higher than the one being given.
+func CanAssignRole(directory GuildDirectory, serverID, roleID string) error {
+	roles, err := directory.Roles(serverID)
+	if err != nil {
+		return err
+	}
+	member, err := directory.Member(serverID, directory.BotID())
+	if err != nil {
+		return err
+	}
+	var role *Role
+	var permissions Permissions
+	highest := -1
+	for _, r := range roles {
+		if r.ID == roleID {
+			role = r
+		}
+		// The @everyone role shares its ID with the server
+		if r.ID == serverID || utils.Contains(member.Roles, r.ID) {
+			permissions |= r.Permissions
+			if r.Position > highest {
+				highest = r.Position
+			}
+		}
+	}
+	if role == nil {
+		return fmt.Errorf("role doesn't exist")
+	}
+	if role.Managed {
+		return fmt.Errorf("role is managed by an integration")
+	}
+	if !permissions.Has(PermissionManageRoles) {
+		return fmt.Errorf("the bot needs the Manage Roles permission")
+	}
+	if role.Position >= highest {
+		return fmt.Errorf("the bots highest role must be above the role")
+	}
+	return nil
+}
+
+func HasRole(directory GuildDirectory, serverID, userID, roleID string) bool {
+	member, err := directory.Member(serverID, userID)
+	if err != nil {
+		return false
+	}
+	return utils.Contains(member.Roles, roleID)
+}
+
+// DisplayName returns the users nickname on the server, or their username if they don't have one. It
+// returns an empty string if the user can't be found.
+func DisplayName(directory GuildDirectory, serverID, userID string) string {
+	member, err := directory.Member(serverID, userID)
+	if err != nil {
+		return ""
+	}
+	return member.DisplayName()
+}
+
+func LogAndSend(messenger Messenger, channelID, serverID, message string, err error) error {
+	if err != nil {
+		log.Error(err)
+	}
+	log.Info(fmt.Sprintf("Sending message to channel %s on server %s: '%s'", channelID, serverID, message))
+	if err := messenger.SendMessage(channelID, message); err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
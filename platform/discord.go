@@ -0,0 +1,269 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+	commonerrors "github.com/joshjennings98/discord-bot/errors"
+)
+
+const avatarSize = "128"
+
+// discordPermissions maps Permissions to their Discord equivalents.
+var discordPermissions = map[Permissions]int64{
+	PermissionSendMessages:  discordgo.PermissionSendMessages,
+	PermissionEmbedLinks:    discordgo.PermissionEmbedLinks,
+	PermissionManageRoles:   discordgo.PermissionManageRoles,
+	PermissionManageServer:  discordgo.PermissionManageServer,
+	PermissionAdministrator: discordgo.PermissionAdministrator,
+}
+
+var discordOptionTypes = map[OptionType]discordgo.ApplicationCommandOptionType{
+	OptionString:  discordgo.ApplicationCommandOptionString,
+	OptionInteger: discordgo.ApplicationCommandOptionInteger,
+	OptionUser:    discordgo.ApplicationCommandOptionUser,
+	OptionRole:    discordgo.ApplicationCommandOptionRole,
+}
+
+// DiscordSession is the Messenger and GuildDirectory for a discordgo session.
+type DiscordSession struct {
+	session *discordgo.Session
+}
+
+func NewDiscordSession(session *discordgo.Session) *DiscordSession {
+	return &DiscordSession{session: session}
+}
+
+// NewMessage converts a message from Discord.
+func NewMessage(m *discordgo.MessageCreate) *Message {
+	message := &Message{ID: m.ID, ServerID: m.GuildID, ChannelID: m.ChannelID, Content: m.Content}
+	if m.Author != nil {
+		message.AuthorID = m.Author.ID
+	}
+	return message
+}
+
+// NewInteraction converts an interaction from Discord. It returns false for interactions other than slash
+// commands and autocompletion.
+func NewInteraction(i *discordgo.InteractionCreate) (interaction *Interaction, ok bool) {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+	default:
+		return nil, false
+	}
+	interaction = &Interaction{
+		ID:        i.ID,
+		Token:     i.Token,
+		Type:      InteractionCommand,
+		ServerID:  i.GuildID,
+		ChannelID: i.ChannelID,
+	}
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		interaction.Type = InteractionAutocomplete
+	}
+	if i.Member != nil {
+		interaction.UserID = i.Member.User.ID
+	} else if i.User != nil {
+		interaction.UserID = i.User.ID
+	}
+	data := i.ApplicationCommandData()
+	interaction.Command = data.Name
+	for _, option := range data.Options {
+		interaction.Options = append(interaction.Options, &InteractionOption{
+			Name:    option.Name,
+			Value:   optionValue(option),
+			Focused: option.Focused,
+		})
+	}
+	return interaction, true
+}
+
+func optionValue(option *discordgo.ApplicationCommandInteractionDataOption) string {
+	switch option.Type {
+	case discordgo.ApplicationCommandOptionInteger:
+		return strconv.FormatInt(option.IntValue(), 10)
+	default:
+		return fmt.Sprintf("%v", option.Value)
+	}
+}
+
+// discordInteraction is the part of a discordgo interaction needed to respond to it.
+func discordInteraction(interaction *Interaction) *discordgo.Interaction {
+	return &discordgo.Interaction{ID: interaction.ID, Token: interaction.Token}
+}
+
+func discordEmbed(embed *Embed) *discordgo.MessageEmbed {
+	discordEmbed := &discordgo.MessageEmbed{
+		Title:       embed.Title,
+		Description: embed.Description,
+		Color:       embed.Colour,
+	}
+	if embed.ThumbnailURL != "" {
+		discordEmbed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: embed.ThumbnailURL}
+	}
+	for _, field := range embed.Fields {
+		discordEmbed.Fields = append(discordEmbed.Fields, &discordgo.MessageEmbedField{Name: field.Name, Value: field.Value, Inline: field.Inline})
+	}
+	return discordEmbed
+}
+
+func discordEmbeds(embeds []*Embed) (discordEmbeds []*discordgo.MessageEmbed) {
+	for _, embed := range embeds {
+		discordEmbeds = append(discordEmbeds, discordEmbed(embed))
+	}
+	return
+}
+
+func discordCommand(command *SlashCommand) *discordgo.ApplicationCommand {
+	applicationCommand := &discordgo.ApplicationCommand{
+		Name:        command.Name,
+		Description: command.Description,
+	}
+	for _, option := range command.Options {
+		discordOption := &discordgo.ApplicationCommandOption{
+			Type:         discordOptionTypes[option.Type],
+			Name:         option.Name,
+			Description:  option.Description,
+			Required:     option.Required,
+			Autocomplete: option.Autocomplete,
+		}
+		if option.MinValue != nil {
+			minValue := float64(*option.MinValue)
+			discordOption.MinValue = &minValue
+		}
+		if option.MaxValue != nil {
+			discordOption.MaxValue = float64(*option.MaxValue)
+		}
+		for _, choice := range option.Choices {
+			discordOption.Choices = append(discordOption.Choices, &discordgo.ApplicationCommandOptionChoice{Name: choice, Value: choice})
+		}
+		applicationCommand.Options = append(applicationCommand.Options, discordOption)
+	}
+	return applicationCommand
+}
+
+func fromDiscordPermissions(discordPermissionBits int64) (permissions Permissions) {
+	for permission, bit := range discordPermissions {
+		if discordPermissionBits&bit != 0 {
+			permissions |= permission
+		}
+	}
+	return
+}
+
+func (d *DiscordSession) SendMessage(channelID, content string) error {
+	_, err := d.session.ChannelMessageSend(channelID, content)
+	return restError(err)
+}
+
+func (d *DiscordSession) SendEmbed(channelID, content string, embed *Embed) error {
+	_, err := d.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content: content,
+		Embeds:  []*discordgo.MessageEmbed{discordEmbed(embed)},
+	})
+	return restError(err)
+}
+
+func (d *DiscordSession) RespondToInteraction(interaction *Interaction, content string, embeds []*Embed) error {
+	return d.session.InteractionRespond(discordInteraction(interaction), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: content, Embeds: discordEmbeds(embeds)},
+	})
+}
+
+func (d *DiscordSession) FollowupInteraction(interaction *Interaction, content string, embeds []*Embed) error {
+	params := &discordgo.WebhookParams{Content: content, Embeds: discordEmbeds(embeds)}
+	_, err := d.session.FollowupMessageCreate(d.BotID(), discordInteraction(interaction), false, params)
+	return err
+}
+
+func (d *DiscordSession) Autocomplete(interaction *Interaction, choices []string) error {
+	var discordChoices []*discordgo.ApplicationCommandOptionChoice
+	for _, choice := range choices {
+		discordChoices = append(discordChoices, &discordgo.ApplicationCommandOptionChoice{Name: choice, Value: choice})
+	}
+	return d.session.InteractionRespond(discordInteraction(interaction), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: discordChoices},
+	})
+}
+
+func (d *DiscordSession) RegisterCommands(commands []*SlashCommand) error {
+	var applicationCommands []*discordgo.ApplicationCommand
+	for _, command := range commands {
+		applicationCommands = append(applicationCommands, discordCommand(command))
+	}
+	_, err := d.session.ApplicationCommandBulkOverwrite(d.BotID(), "", applicationCommands)
+	return err
+}
+
+func (d *DiscordSession) BotID() string {
+	return d.session.State.User.ID
+}
+
+func (d *DiscordSession) Member(serverID, userID string) (*Member, error) {
+	member, err := d.session.GuildMember(serverID, userID)
+	if err != nil {
+		return nil, restError(err)
+	}
+	return &Member{
+		UserID:    member.User.ID,
+		Username:  member.User.Username,
+		Nick:      member.Nick,
+		AvatarURL: member.AvatarURL(avatarSize),
+		Roles:     member.Roles,
+	}, nil
+}
+
+func (d *DiscordSession) Roles(serverID string) ([]*Role, error) {
+	discordRoles, err := d.session.GuildRoles(serverID)
+	if err != nil {
+		return nil, err
+	}
+	var roles []*Role
+	for _, role := range discordRoles {
+		roles = append(roles, &Role{
+			ID:          role.ID,
+			Name:        role.Name,
+			Position:    role.Position,
+			Permissions: fromDiscordPermissions(role.Permissions),
+			Managed:     role.Managed,
+		})
+	}
+	return roles, nil
+}
+
+func (d *DiscordSession) Permissions(userID, channelID string) (Permissions, error) {
+	permissions, err := d.session.UserChannelPermissions(userID, channelID)
+	if err != nil {
+		return 0, err
+	}
+	return fromDiscordPermissions(permissions), nil
+}
+
+func (d *DiscordSession) AddMemberRole(serverID, userID, roleID string) error {
+	return restError(d.session.GuildMemberRoleAdd(serverID, userID, roleID))
+}
+
+func (d *DiscordSession) RemoveMemberRole(serverID, userID, roleID string) error {
+	return restError(d.session.GuildMemberRoleRemove(serverID, userID, roleID))
+}
+
+// restError converts 404 responses into commonerrors.ErrNotFound and 403 responses into
+// commonerrors.ErrForbidden.
+func restError(err error) error {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) || restErr.Response == nil {
+		return err
+	}
+	switch restErr.Response.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", commonerrors.ErrNotFound, err.Error())
+	case http.StatusForbidden:
+		return fmt.Errorf("%w: %s", commonerrors.ErrForbidden, err.Error())
+	}
+	return err
+}
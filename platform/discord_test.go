@@ -0,0 +1,54 @@
+package platform
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestFromDiscordPermissions(t *testing.T) {
+	permissions := fromDiscordPermissions(discordgo.PermissionSendMessages | discordgo.PermissionManageRoles | discordgo.PermissionAddReactions)
+	if permissions != PermissionSendMessages|PermissionManageRoles {
+		t.Errorf("unexpected permissions %b", permissions)
+	}
+	if permissions.Has(PermissionEmbedLinks) {
+		t.Errorf("expected no permission to embed links")
+	}
+	if !fromDiscordPermissions(discordgo.PermissionAdministrator).Has(PermissionEmbedLinks) {
+		t.Errorf("expected administrators to have every permission")
+	}
+}
+
+func TestDiscordCommand(t *testing.T) {
+	minHour, maxHour := 0, 23
+	command := discordCommand(&SlashCommand{Name: "setup", Description: "run the setup", Options: []*SlashCommandOption{
+		{Type: OptionInteger, Name: "hour", Description: "hour", Required: true, MinValue: &minHour, MaxValue: &maxHour},
+		{Type: OptionString, Name: "action", Description: "action", Choices: []string{"add", "list"}},
+	}})
+	hour, action := command.Options[0], command.Options[1]
+	if hour.Type != discordgo.ApplicationCommandOptionInteger || hour.MinValue == nil || *hour.MinValue != 0 || hour.MaxValue != 23 {
+		t.Errorf("unexpected hour option %+v", hour)
+	}
+	if action.Type != discordgo.ApplicationCommandOptionString || len(action.Choices) != 2 || action.Choices[1].Value != "list" {
+		t.Errorf("unexpected action option %+v", action)
+	}
+}
+
+func TestRestErrorsArePermanent(t *testing.T) {
+	for status, permanent := range map[int]bool{
+		http.StatusNotFound:            true,
+		http.StatusForbidden:           true,
+		http.StatusInternalServerError: false,
+		http.StatusTooManyRequests:     false,
+	} {
+		err := restError(&discordgo.RESTError{Response: &http.Response{StatusCode: status}})
+		if Permanent(err) != permanent {
+			t.Errorf("expected status %d to be permanent: %t", status, permanent)
+		}
+	}
+	if Permanent(errors.New("connection reset")) {
+		t.Errorf("expected errors that aren't responses not to be permanent")
+	}
+}
@@ -0,0 +1,172 @@
+package platform
+
+import (
+	"errors"
+
+	commonerrors "github.com/joshjennings98/discord-bot/errors"
+)
+
+// Messenger sends messages and responds to interactions. Messages to channels that don't exist return
+// commonerrors.ErrNotFound and messages the bot isn't allowed to send return commonerrors.ErrForbidden.
+type Messenger interface {
+	SendMessage(channelID, content string) error
+	// SendEmbed sends an embed, with content alongside it if content isn't empty.
+	SendEmbed(channelID, content string, embed *Embed) error
+	RespondToInteraction(interaction *Interaction, content string, embeds []*Embed) error
+	FollowupInteraction(interaction *Interaction, content string, embeds []*Embed) error
+	// Autocomplete suggests values for the option the user is typing.
+	Autocomplete(interaction *Interaction, choices []string) error
+	// RegisterCommands replaces the registered slash commands.
+	RegisterCommands(commands []*SlashCommand) error
+}
+
+// Permanent reports whether sending a message failed in a way that trying again won't fix, e.g. because
+// the channel was deleted or the bot isn't allowed to send messages to it.
+func Permanent(err error) bool {
+	return errors.Is(err, commonerrors.ErrNotFound) || errors.Is(err, commonerrors.ErrForbidden)
+}
+
+// GuildDirectory looks up and manages the members, roles and permissions of servers. Lookups of members
+// that can't be found return commonerrors.ErrNotFound.
+type GuildDirectory interface {
+	// BotID is the user ID of the bot itself.
+	BotID() string
+	Member(serverID, userID string) (*Member, error)
+	Roles(serverID string) ([]*Role, error)
+	// Permissions returns the permissions the user has in the channel.
+	Permissions(userID, channelID string) (Permissions, error)
+	AddMemberRole(serverID, userID, roleID string) error
+	RemoveMemberRole(serverID, userID, roleID string) error
+}
+
+// Permissions is a set of the permissions the bot cares about.
+type Permissions int64
+
+const (
+	PermissionSendMessages Permissions = 1 << iota
+	PermissionEmbedLinks
+	PermissionManageRoles
+	PermissionManageServer
+	PermissionAdministrator
+
+	PermissionAll = PermissionSendMessages | PermissionEmbedLinks | PermissionManageRoles | PermissionManageServer | PermissionAdministrator
+)
+
+// Has reports whether any of the permissions are set, administrators have every permission.
+func (p Permissions) Has(permissions Permissions) bool {
+	return p&(permissions|PermissionAdministrator) != 0
+}
+
+// Member is a user in a server.
+type Member struct {
+	UserID   string
+	Username string
+	Nick     string
+	// AvatarURL is empty if the avatar isn't known
+	AvatarURL string
+	Roles     []string
+}
+
+// DisplayName returns the members nickname on the server, or their username if they don't have one.
+func (m *Member) DisplayName() string {
+	if m.Nick != "" {
+		return m.Nick
+	}
+	return m.Username
+}
+
+// Role is a role in a server. Roles with a higher Position are above those with a lower one.
+type Role struct {
+	ID          string
+	Name        string
+	Position    int
+	Permissions Permissions
+	// Managed roles belong to an integration and can't be given to members
+	Managed bool
+}
+
+// Embed is a message with a title, description and fields, shown in a box.
+type Embed struct {
+	Title        string
+	Description  string
+	Colour       int
+	ThumbnailURL string
+	Fields       []*EmbedField
+}
+
+type EmbedField struct {
+	Name   string
+	Value  string
+	Inline bool
+}
+
+// Message is a message sent by a user in a channel.
+type Message struct {
+	ID        string
+	ServerID  string
+	ChannelID string
+	AuthorID  string
+	Content   string
+}
+
+type InteractionType int
+
+const (
+	// InteractionCommand is a user running a slash command
+	InteractionCommand InteractionType = iota + 1
+	// InteractionAutocomplete is a user typing an option that has Autocomplete set
+	InteractionAutocomplete
+)
+
+// Interaction is a slash command from a user, which is replied to with RespondToInteraction and
+// FollowupInteraction.
+type Interaction struct {
+	// ID and Token identify the interaction when responding to it
+	ID    string
+	Token string
+	Type  InteractionType
+
+	ServerID  string
+	ChannelID string
+	UserID    string
+	// Command is the name of the slash command
+	Command string
+	Options []*InteractionOption
+}
+
+// InteractionOption is an option given to a slash command. Values of every type are converted to strings.
+type InteractionOption struct {
+	Name  string
+	Value string
+	// Focused is set for the option being autocompleted
+	Focused bool
+}
+
+type OptionType int
+
+const (
+	OptionString OptionType = iota
+	OptionInteger
+	OptionUser
+	OptionRole
+)
+
+// SlashCommand is a command users can run by typing '/' followed by its name.
+type SlashCommand struct {
+	Name        string
+	Description string
+	Options     []*SlashCommandOption
+}
+
+type SlashCommandOption struct {
+	Type        OptionType
+	Name        string
+	Description string
+	Required    bool
+	// Autocomplete options get their choices from Autocomplete as the user types
+	Autocomplete bool
+	Choices      []string
+	// MinValue and MaxValue limit OptionInteger options when set
+	MinValue *int
+	MaxValue *int
+}
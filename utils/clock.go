@@ -0,0 +1,23 @@
+package utils
+
+import "time"
+
+// Clock tells the time and creates timers, so anything that depends on the date can be run at any time.
+type Clock interface {
+	Now() time.Time
+	// After returns a channel that receives the time once d has passed.
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SystemClock is the real time.
+var SystemClock Clock = systemClock{}
@@ -4,31 +4,14 @@ import (
 	"fmt"
 	"strconv"
 	"time"
-
-	"github.com/bwmarrin/discordgo"
-	log "github.com/sirupsen/logrus"
 )
 
-func IsUser(user string, s *discordgo.Session, serverID string) (b bool, id string) {
-	_, err := s.GuildMember(serverID, user)
-
-	if err != nil {
-		return false, user
-	}
-
-	return true, user
-}
-
 func GetIDFromMention(user string) string {
 	return RemoveChars(user, []string{"<", ">", "@", "!"})
 }
 
-func LogAndSend(session *discordgo.Session, channelID, serverID, message string, err error) {
-	if err != nil {
-		log.Error(err)
-	}
-	log.Info(fmt.Sprintf("Sending message to channel %s on server %s: '%s'", channelID, serverID, message))
-	session.ChannelMessageSend(channelID, message)
+func GetRoleIDFromMention(role string) string {
+	return RemoveChars(role, []string{"<", ">", "@", "&"})
 }
 
 func DatabaseFromServerID(server string) string {
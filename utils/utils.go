@@ -2,11 +2,16 @@ package utils
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/pflag"
@@ -27,6 +32,23 @@ const (
 	FullDateFormat   = "02/01/06 03:04:05 PM"
 )
 
+var (
+	timezoneNames     []string
+	timezoneNamesOnce sync.Once
+)
+
+// Used when the zoneinfo database can't be listed.
+var commonTimezones = []string{
+	"UTC",
+	"Europe/London", "Europe/Dublin", "Europe/Paris", "Europe/Berlin", "Europe/Madrid", "Europe/Rome",
+	"Europe/Amsterdam", "Europe/Stockholm", "Europe/Warsaw", "Europe/Athens", "Europe/Moscow",
+	"America/New_York", "America/Chicago", "America/Denver", "America/Los_Angeles", "America/Toronto",
+	"America/Mexico_City", "America/Sao_Paulo", "America/Buenos_Aires",
+	"Asia/Dubai", "Asia/Kolkata", "Asia/Singapore", "Asia/Shanghai", "Asia/Hong_Kong", "Asia/Tokyo", "Asia/Seoul",
+	"Australia/Perth", "Australia/Sydney", "Australia/Melbourne", "Pacific/Auckland",
+	"Africa/Cairo", "Africa/Johannesburg", "Africa/Lagos",
+}
+
 var monthDays = [12]Month{
 	{"January", 31},
 	{"February", 28},
@@ -87,6 +109,34 @@ func BindFlagToEnvironmentVariable(viperSession *viper.Viper, envVarPrefix strin
 	return
 }
 
+// TimezoneNames lists the IANA timezone names available on this system, sorted alphabetically.
+func TimezoneNames() []string {
+	timezoneNamesOnce.Do(func() {
+		root := os.Getenv("ZONEINFO")
+		if root == "" {
+			root = "/usr/share/zoneinfo"
+		}
+		_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			name, err := filepath.Rel(root, path)
+			if err != nil || name == "" || !unicode.IsUpper(rune(name[0])) || strings.Contains(name, ".") {
+				return nil
+			}
+			if _, err := time.LoadLocation(name); err == nil {
+				timezoneNames = append(timezoneNames, name)
+			}
+			return nil
+		})
+		if len(timezoneNames) == 0 {
+			timezoneNames = append(timezoneNames, commonTimezones...)
+		}
+		sort.Strings(timezoneNames)
+	})
+	return timezoneNames
+}
+
 func IsValidDate(s string) bool {
 	re := regexp.MustCompile(`(^((0[1-9]|[12]\d|3[01])\/(0[13578]|1[02]))|((0[1-9]|[12]\d|30)\/(0[13456789]|1[012]))|((0[1-9]|1\d|2[0-8])\/02)|(29\/02))$`)
 	return re.MatchString(s)
@@ -100,26 +150,6 @@ func RemoveChars(s string, chars []string) (newS string) {
 	return
 }
 
-func InHourInterval(n int, timeToCheck time.Time) bool {
-	startTime := fmt.Sprintf("%s:00:00", AppendZero(n))
-	endTime := fmt.Sprintf("%s:00:00", AppendZero(n+1))
-
-	start, err := time.Parse(SimpleTimeFormat, startTime)
-	if err != nil {
-		return false
-	}
-	end, err := time.Parse(SimpleTimeFormat, endTime)
-	if err != nil {
-		return false
-	}
-	check, err := time.Parse(SimpleTimeFormat, timeToCheck.Format(SimpleTimeFormat))
-	if err != nil {
-		return false
-	}
-
-	return !check.Before(start) && !check.After(end)
-}
-
 func SplitCommand(input string) []string {
 	s := strings.Split(input, " ")
 	var r []string
@@ -154,14 +184,6 @@ func ConvertYearDayToDate(day string) (date string, err error) {
 	return "", fmt.Errorf("error parsing day as date %w", err)
 }
 
-func DaysInThisYear() int {
-	y := time.Now().Year()
-	if (y%4 == 0 && y%100 != 0) || y%400 == 0 {
-		return 366
-	}
-	return 365
-}
-
 func Contains(arr interface{}, elem interface{}) bool {
 	arrV := reflect.ValueOf(arr)
 	if arrV.Kind() == reflect.Slice {